@@ -0,0 +1,208 @@
+package aquamarine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+// Goの値をobject.Objectに変換する
+//
+//	nil, nilポインタ      -> null
+//	bool                 -> 真偽値
+//	整数型               -> 整数 (int64に収まらなければエラー)
+//	float32, float64     -> 浮動小数点数
+//	string               -> 文字列
+//	スライス・配列        -> 配列
+//	マップ               -> ハッシュ (キーは整数・浮動小数点数・文字列・真偽値)
+//	構造体               -> 公開されたフィールドのハッシュ
+//	                       (キーは `aquamarine:"name"` タグかフィールド名)
+//	object.Object        -> そのまま
+func ToObject(v interface{}) (object.Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return v, nil
+	case bool:
+		return nativeBool(v), nil
+	case string:
+		return &object.String{Value: v}, nil
+	}
+
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(rv reflect.Value) (object.Object, error) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return nativeBool(rv.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("aquamarine: integer overflows int64: %d", rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: rv.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, rv.Len())
+		for i := range elements {
+			el, err := ToObject(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return mapToHash(rv)
+
+	case reflect.Struct:
+		return structToHash(rv)
+
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(rv.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("aquamarine: cannot convert %s to an object", rv.Type())
+}
+
+// マップの順序は決まらないので、キーの表示順に並べたハッシュにする
+func mapToHash(rv reflect.Value) (object.Object, error) {
+	pairs := make([]object.HashPair, 0, rv.Len())
+
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := ToObject(iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(object.Hashable); !ok {
+			return nil, fmt.Errorf("aquamarine: unusable as hash key: %s", key.Type())
+		}
+		value, err := ToObject(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, object.HashPair{Key: key, Value: value})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+
+	hash := object.NewHash()
+	for _, pair := range pairs {
+		hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+	}
+	return hash, nil
+}
+
+// フィールドの順に並べたハッシュにする
+func structToHash(rv reflect.Value) (object.Object, error) {
+	hash := object.NewHash()
+
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		value, err := ToObject(rv.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		key := &object.String{Value: fieldName(f)}
+		hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+// object.ObjectをGoの値に変換する
+//
+//	null         -> nil
+//	真偽値       -> bool
+//	整数         -> int64
+//	浮動小数点数  -> float64
+//	文字列       -> string
+//	配列         -> []interface{}
+//	ハッシュ     -> キーがすべて文字列ならmap[string]interface{}、
+//	               そうでなければmap[interface{}]interface{}
+//	関数など     -> object.Objectのまま
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.NULL, *object.NULLSTRING:
+		return nil
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			values[i] = FromObject(el)
+		}
+		return values
+
+	case *object.Hash:
+		items := obj.Items()
+		allStrings := true
+		for _, pair := range items {
+			if _, ok := pair.Key.(*object.String); !ok {
+				allStrings = false
+				break
+			}
+		}
+
+		if allStrings {
+			m := make(map[string]interface{}, len(items))
+			for _, pair := range items {
+				m[pair.Key.(*object.String).Value] = FromObject(pair.Value)
+			}
+			return m
+		}
+		m := make(map[interface{}]interface{}, len(items))
+		for _, pair := range items {
+			m[FromObject(pair.Key)] = FromObject(pair.Value)
+		}
+		return m
+	}
+
+	return obj
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
@@ -0,0 +1,122 @@
+package aquamarine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+func TestToObject(t *testing.T) {
+	var nilPtr *int
+	n := 5
+
+	tests := []struct {
+		input    interface{}
+		expected string // Inspectの結果
+	}{
+		{nil, "null"},
+		{nilPtr, "null"},
+		{&n, "5"},
+		{true, "true"},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint32(7), "7"},
+		{2.5, "2.5"},
+		{float32(0.5), "0.5"},
+		{"hi", `"hi"`},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, `["a", "b"]`},
+		{[]interface{}{1, "x", nil, []bool{true}}, `[1, "x", null, [true]]`},
+		{map[string]int{"b": 2, "a": 1}, `{"a": 1, "b": 2}`},
+		{map[int]string{2: "y", 1: "x"}, `{1: "x", 2: "y"}`},
+		{&object.Integer{Value: 9}, "9"},
+		{struct {
+			Name  string
+			Month int `aquamarine:"month"`
+			note  string
+		}{"aquamarine", 3, ""}, `{"Name": "aquamarine", "month": 3}`},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	tests := []interface{}{
+		struct{ C chan int }{},
+		make(chan int),
+		uint64(1 << 63),
+		map[[2]int]int{{1, 2}: 3},
+		[]interface{}{1, func() {}},
+	}
+
+	for _, tt := range tests {
+		if _, err := ToObject(tt); err == nil {
+			t.Errorf("ToObject(%T) expected error", tt)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	strKeys := object.NewHash()
+	key := &object.String{Value: "a"}
+	strKeys.Set(key.HashKey(), object.HashPair{Key: key, Value: &object.Integer{Value: 1}})
+
+	mixedKeys := object.NewHash()
+	intKey := &object.Integer{Value: 1}
+	mixedKeys.Set(intKey.HashKey(), object.HashPair{Key: intKey, Value: evaluator.TRUE})
+
+	fn := &object.Function{}
+
+	tests := []struct {
+		input    object.Object
+		expected interface{}
+	}{
+		{evaluator.NULL, nil},
+		{evaluator.TRUE, true},
+		{&object.Integer{Value: 3}, int64(3)},
+		{&object.Float{Value: 1.5}, 1.5},
+		{&object.String{Value: "s"}, "s"},
+		{&object.Array{Elements: []object.Object{&object.Integer{Value: 1}, evaluator.NULL}}, []interface{}{int64(1), nil}},
+		{strKeys, map[string]interface{}{"a": int64(1)}},
+		{mixedKeys, map[interface{}]interface{}{int64(1): true}},
+		{fn, fn},
+	}
+
+	for _, tt := range tests {
+		testValue(t, FromObject(tt.input), tt.expected)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	input := map[string]interface{}{
+		"name":   "aquamarine",
+		"month":  int64(3),
+		"ratio":  0.5,
+		"tags":   []interface{}{"gem", true},
+		"nested": map[string]interface{}{"empty": nil},
+	}
+
+	obj, err := ToObject(input)
+	if err != nil {
+		t.Fatalf("ToObject returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), input)
+}
+
+func testValue(t *testing.T, got, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong value. want=%#v, got=%#v", expected, got)
+	}
+}
@@ -0,0 +1,376 @@
+package aquamarine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+)
+
+// Goの関数をこのインスタンスの組み込み関数として登録する
+//
+// 引数はobject.Objectから関数の引数の型に、戻り値はToObjectで変換する
+// 引数と戻り値に使える型は、整数型・浮動小数点数型・string・bool・
+// スライス・配列・マップ・構造体・ポインタ・interface{}・object.Object
+// 最後の戻り値がerrorのときは、nilでなければ実行時エラーになる
+// 戻り値が (error以外に) 複数あるときは配列にする
+//
+//	interp.RegisterFunc("add", func(a, b int64) int64 { return a + b })
+//	interp.RegisterFunc("load", func(path string) (string, error) { ... })
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := wrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, builtin)
+	return nil
+}
+
+// Goの関数をobject.Builtinで包む
+func wrapFunc(name string, fn interface{}) (*object.Builtin, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("aquamarine: %s: not a function: %T", name, fn)
+	}
+
+	ft := fv.Type()
+	for j := 0; j < ft.NumIn(); j++ {
+		t := ft.In(j)
+		if ft.IsVariadic() && j == ft.NumIn()-1 {
+			t = t.Elem()
+		}
+		if !convertible(t, map[reflect.Type]bool{}) {
+			return nil, fmt.Errorf("aquamarine: %s: unsupported parameter type %s", name, t)
+		}
+	}
+	for j := 0; j < ft.NumOut(); j++ {
+		t := ft.Out(j)
+		if j == ft.NumOut()-1 && t == errorType {
+			continue
+		}
+		if !convertible(t, map[reflect.Type]bool{}) {
+			return nil, fmt.Errorf("aquamarine: %s: unsupported result type %s", name, t)
+		}
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return callFunc(name, fv, args)
+	}}, nil
+}
+
+// 変換できる型か (seenは再帰的な型の検査中の型)
+func convertible(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+
+	case reflect.Interface:
+		return t.NumMethod() == 0 || t == objectType
+
+	case reflect.Slice, reflect.Array, reflect.Ptr:
+		seen[t] = true
+		return convertible(t.Elem(), seen)
+
+	case reflect.Map:
+		seen[t] = true
+		return convertible(t.Key(), seen) && convertible(t.Elem(), seen)
+
+	case reflect.Struct:
+		seen[t] = true
+		for j := 0; j < t.NumField(); j++ {
+			f := t.Field(j)
+			if f.PkgPath == "" && !convertible(f.Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// 引数を変換して呼び出し、戻り値をオブジェクトにする
+func callFunc(name string, fv reflect.Value, args []object.Object) (result object.Object) {
+	ft := fv.Type()
+
+	nin := ft.NumIn()
+	if ft.IsVariadic() {
+		if len(args) < nin-1 {
+			return newError("wrong number of arguments to %s: want at least %d, got=%d", name, nin-1, len(args))
+		}
+	} else if len(args) != nin {
+		return newError("wrong number of arguments to %s: want=%d, got=%d", name, nin, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for j, arg := range args {
+		var t reflect.Type
+		if ft.IsVariadic() && j >= nin-1 {
+			t = ft.In(nin - 1).Elem()
+		} else {
+			t = ft.In(j)
+		}
+
+		v, err := fromObject(arg, t)
+		if err != nil {
+			return newError("argument %d to %s: %s", j+1, name, err)
+		}
+		in[j] = v
+	}
+
+	// Goの関数の中のpanicも実行時エラーにする
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("%s: panic: %v", name, r)
+		}
+	}()
+
+	out := fv.Call(in)
+
+	if n := len(out); n > 0 && ft.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			return newError("%s", err.Error())
+		}
+		out = out[:n-1]
+	}
+
+	values := make([]object.Object, len(out))
+	for j, v := range out {
+		obj, err := ToObject(v.Interface())
+		if err != nil {
+			return newError("%s: result %d: %s", name, j+1, err)
+		}
+		values[j] = obj
+	}
+
+	switch len(values) {
+	case 0:
+		return evaluator.NULL
+	case 1:
+		return values[0]
+	}
+	return &object.Array{Elements: values}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// 型が合わないときのエラー
+type mismatchError struct {
+	path     string // 配列の要素やハッシュのキーの位置 ([0]["name"] など)
+	expected string
+	got      object.Object
+}
+
+func (e *mismatchError) Error() string {
+	msg := fmt.Sprintf("expected %s, got %s", e.expected, e.got.Type())
+	if e.path != "" {
+		msg = e.path + ": " + msg
+	}
+	return msg
+}
+
+// オブジェクトをGoの型tの値に変換する
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	mismatch := func(expected string) (reflect.Value, error) {
+		return reflect.Value{}, &mismatchError{expected: expected, got: obj}
+	}
+
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		v := reflect.New(t).Elem()
+		if goValue := FromObject(obj); goValue != nil {
+			v.Set(reflect.ValueOf(goValue))
+		}
+		return v, nil
+
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch("BOOLEAN")
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch("STRING")
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch("INTEGER")
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(n.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", n.Value, t)
+		}
+		v.SetInt(n.Value)
+		return v, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch("INTEGER")
+		}
+		v := reflect.New(t).Elem()
+		if n.Value < 0 || v.OverflowUint(uint64(n.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", n.Value, t)
+		}
+		v.SetUint(uint64(n.Value))
+		return v, nil
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := obj.(type) {
+		case *object.Integer:
+			f = float64(n.Value)
+		case *object.Float:
+			f = n.Value
+		default:
+			return mismatch("FLOAT")
+		}
+		v := reflect.New(t).Elem()
+		if t.Kind() == reflect.Float32 && !math.IsInf(f, 0) && v.OverflowFloat(f) {
+			return reflect.Value{}, fmt.Errorf("%g overflows %s", f, t)
+		}
+		v.SetFloat(f)
+		return v, nil
+
+	case reflect.Ptr:
+		if _, ok := obj.(*object.NULL); ok {
+			return reflect.Zero(t), nil
+		}
+		elem, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+
+	case reflect.Slice, reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch("ARRAY")
+		}
+
+		var v reflect.Value
+		if t.Kind() == reflect.Slice {
+			v = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		} else {
+			if len(arr.Elements) != t.Len() {
+				return reflect.Value{}, fmt.Errorf("expected ARRAY of length %d, got length %d", t.Len(), len(arr.Elements))
+			}
+			v = reflect.New(t).Elem()
+		}
+		for j, el := range arr.Elements {
+			ev, err := fromObject(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, atPath(fmt.Sprintf("[%d]", j), err)
+			}
+			v.Index(j).Set(ev)
+		}
+		return v, nil
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch("HASH")
+		}
+
+		v := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Items() {
+			path := "[" + pair.Key.Inspect() + "]"
+			kv, err := fromObject(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, atPath(path+" (key)", err)
+			}
+			ev, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, atPath(path, err)
+			}
+			v.SetMapIndex(kv, ev)
+		}
+		return v, nil
+
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch("HASH")
+		}
+
+		v := reflect.New(t).Elem()
+		for _, pair := range hash.Items() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("expected STRING keys for %s, got %s", t, pair.Key.Type())
+			}
+			field, ok := structField(t, key.Value)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("unknown field %q for %s", key.Value, t)
+			}
+			fv, err := fromObject(pair.Value, field.Type)
+			if err != nil {
+				return reflect.Value{}, atPath("["+pair.Key.Inspect()+"]", err)
+			}
+			v.FieldByIndex(field.Index).Set(fv)
+		}
+		return v, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+}
+
+// 入れ子になった値のエラーに位置を付ける
+func atPath(path string, err error) error {
+	if m, ok := err.(*mismatchError); ok {
+		return &mismatchError{path: path + m.path, expected: m.expected, got: m.got}
+	}
+	return fmt.Errorf("%s: %s", path, err)
+}
+
+// ハッシュのキーに対応する構造体のフィールド
+// `aquamarine:"name"` タグがあればその名前、無ければフィールド名 (大文字小文字は区別しない)
+func structField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for j := 0; j < t.NumField(); j++ {
+		f := t.Field(j)
+		if f.PkgPath != "" {
+			continue
+		}
+		if name := fieldName(f); name == key || strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// 構造体のフィールドのハッシュでのキー
+func fieldName(f reflect.StructField) string {
+	if tag := f.Tag.Get("aquamarine"); tag != "" {
+		return tag
+	}
+	return f.Name
+}
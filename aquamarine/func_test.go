@@ -0,0 +1,148 @@
+package aquamarine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+type point struct {
+	X, Y  int64
+	Label string `aquamarine:"label"`
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New()
+
+	funcs := map[string]interface{}{
+		"add":    func(a, b int64) int64 { return a + b },
+		"half":   func(x float64) float64 { return x / 2 },
+		"small":  func(n int8) int8 { return n },
+		"repeat": func(s string, n int) string { return strings.Repeat(s, n) },
+		"not":    func(b bool) bool { return !b },
+		"sum": func(xs []int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"join": func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"count": func(m map[string]int) int {
+			return len(m)
+		},
+		"move": func(p point, dx int64) point {
+			p.X += dx
+			return p
+		},
+		"origin": func() *point { return nil },
+		"describe": func(v interface{}) string {
+			return fmt.Sprintf("%T", v)
+		},
+		"kind":   func(obj object.Object) string { return string(obj.Type()) },
+		"divmod": func(a, b int64) (int64, int64) { return a / b, a % b },
+		"check": func(n int64) error {
+			if n < 0 {
+				return errors.New("negative number")
+			}
+			return nil
+		},
+		"parse": func(s string) (int64, error) {
+			var n int64
+			_, err := fmt.Sscan(s, &n)
+			return n, err
+		},
+		"boom": func() int { panic("unexpected") },
+	}
+	for name, fn := range funcs {
+		if err := interp.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%s) returned error: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string // Inspectの結果、またはエラーのメッセージ
+	}{
+		{"add(1, 2)", "3"},
+		{"half(3)", "1.5"},
+		{"half(1.5)", "0.75"},
+		{"small(-128)", "-128"},
+		{`repeat("ab", 3)`, `"ababab"`},
+		{"not(false)", "true"},
+		{"sum([1, 2, 3])", "6"},
+		{"sum([])", "0"},
+		{`join("-")`, `""`},
+		{`join("-", "a", "b", "c")`, `"a-b-c"`},
+		{`count({"a": 1, "b": 2})`, "2"},
+		{`move({"x": 1, "y": 2, "label": "p"}, 10)`, `{"X": 11, "Y": 2, "label": "p"}`},
+		{"origin()", "null"},
+		{`describe([1, "a"])`, `"[]interface {}"`},
+		{"describe(if (false) { 1 })", `"<nil>"`},
+		{"kind(fn(x) { x })", `"FUNCTION"`},
+		{"divmod(7, 2)", "[3, 1]"},
+		{"check(1)", "null"},
+		{`parse("42")`, "42"},
+		// 引数の数と型の誤り
+		{"add(1)", "wrong number of arguments to add: want=2, got=1"},
+		{"join()", "wrong number of arguments to join: want at least 1, got=0"},
+		{`add(1, "2")`, "argument 2 to add: expected INTEGER, got STRING"},
+		{"add(1, 2.5)", "argument 2 to add: expected INTEGER, got FLOAT"},
+		{"small(200)", "argument 1 to small: 200 overflows int8"},
+		{`sum([1, "x"])`, "argument 1 to sum: [1]: expected INTEGER, got STRING"},
+		{`count({"a": true})`, `argument 1 to count: ["a"]: expected INTEGER, got BOOLEAN`},
+		{`count({1: 1})`, `argument 1 to count: [1] (key): expected STRING, got INTEGER`},
+		{`move({"z": 1}, 0)`, `argument 1 to move: unknown field "z" for aquamarine.point`},
+		{`join(",", "a", 1)`, "argument 3 to join: expected STRING, got INTEGER"},
+		// errorの戻り値
+		{"check(-1)", "negative number"},
+		{`parse("x")`, "expected integer"},
+		{"boom()", "boom: panic: unexpected"},
+	}
+
+	for _, tt := range tests {
+		obj, err := interp.Eval(context.Background(), tt.input)
+		if err != nil {
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				t.Errorf("%s: unexpected error type %T", tt.input, err)
+				continue
+			}
+			if errObj.Message != tt.expected {
+				t.Errorf("%s: wrong error. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+			}
+			if !errObj.Pos.IsValid() {
+				t.Errorf("%s: error has no position", tt.input)
+			}
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestRegisterFuncInvalid(t *testing.T) {
+	tests := []struct {
+		fn       interface{}
+		expected string
+	}{
+		{42, "aquamarine: f: not a function: int"},
+		{(func())(nil), "aquamarine: f: not a function: func()"},
+		{func(c chan int) {}, "aquamarine: f: unsupported parameter type chan int"},
+		{func(...func()) {}, "aquamarine: f: unsupported parameter type func()"},
+		{func() fmt.Stringer { return nil }, "aquamarine: f: unsupported result type fmt.Stringer"},
+		{func() (error, int) { return nil, 0 }, "aquamarine: f: unsupported result type error"},
+	}
+
+	for _, tt := range tests {
+		err := New().RegisterFunc("f", tt.fn)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("RegisterFunc(%T) wrong error. want=%q, got=%v", tt.fn, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Goのプログラムに Aquamarine を組み込むためのパッケージ
+//
+//	interp := aquamarine.New()
+//	interp.SetGlobal("limit", 10)
+//	result, err := interp.Eval(ctx, `limit * 2`)
+package aquamarine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+)
+
+// エラーの位置に使うソースの名前
+const sourceName = "<eval>"
+
+// インタプリタ
+// 変数や登録した関数はインスタンスごとに別で、他のインスタンスからは見えない
+// 1つのインスタンスを複数のgoroutineから同時に使うことはできない
+//
+// 外の世界を使う組み込み関数 (output, now, readFile など) は、
+// Grantで権限を許可するまで呼び出すとエラーになる
+type Interpreter struct {
+	env    *object.Environment
+	host   *object.Host
+	limits object.Limits
+}
+
+func New() *Interpreter {
+	host := &object.Host{}
+	env := object.NewEnvironment()
+	env.SetHost(host)
+	return &Interpreter{env: env, host: host}
+}
+
+// 構文エラーのため実行できなかった
+type SyntaxError struct {
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.Join(diag.Strings(e.Diagnostics), "\n")
+}
+
+// 組み込み関数の権限を許可する
+//
+//	interp.Grant(object.CapIO | object.CapTime)
+func (i *Interpreter) Grant(caps object.Capability) {
+	i.host.Grants |= caps
+}
+
+// 許可した権限を取り消す
+func (i *Interpreter) Revoke(caps object.Capability) {
+	i.host.Grants &^= caps
+}
+
+// outputの出力先を設定する (既定は標準出力)
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.host.Stdout = w
+}
+
+// nowなどが使う時計を設定する (既定は実際の時刻)
+func (i *Interpreter) SetClock(now func() time.Time) {
+	i.host.Now = now
+}
+
+// argc, argvで参照するコマンドライン引数を設定する
+func (i *Interpreter) SetArgs(args ...string) {
+	i.host.Args = args
+}
+
+// importを使えるようにする
+// モジュールはdirsから探し、このインスタンスで一度だけ評価する
+// (CapFSを許可したときは、今のディレクトリや絶対パスも使える)
+func (i *Interpreter) SetModulePath(dirs ...string) {
+	i.host.Modules = object.NewModules(dirs...)
+}
+
+// 実行の制限を設定する (EvalとCallのたびに手数を数え直す)
+func (i *Interpreter) SetLimits(limits object.Limits) {
+	i.limits = limits
+}
+
+// ソースを実行して最後の値を返す
+// 構文エラーは*SyntaxError、実行時エラーは*object.Errorとして返す
+// ctxが取り消されたり期限を過ぎたりしたときは実行を止めてctx.Err()を返す
+// letで束縛した名前は同じインスタンスの次のEvalからも使える
+func (i *Interpreter) Eval(ctx context.Context, src string) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(sourceName, src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, &SyntaxError{Diagnostics: p.Diagnostics()}
+	}
+
+	return result(ctx, evaluator.EvalContext(ctx, program, i.env, i.limits))
+}
+
+// グローバルな関数・組み込み関数を呼び出す (引数はToObjectで変換する)
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// ctxの取り消し・期限を守りながら関数を呼び出す
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fn, ok := i.env.Get(name)
+	if !ok {
+		builtin, ok := evaluator.LookupBuiltin(name)
+		if !ok {
+			return nil, fmt.Errorf("aquamarine: function not found: %s", name)
+		}
+		fn = builtin
+	}
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("aquamarine: %s is not a function: %s", name, fn.Type())
+	}
+
+	objs := make([]object.Object, len(args))
+	for j, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objs[j] = obj
+	}
+
+	return result(ctx, evaluator.ApplyFunctionContext(ctx, fn, objs, i.limits, i.host))
+}
+
+// グローバル変数を設定する (値はToObjectで変換する)
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// グローバル変数の値 (無ければfalse)
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// このインスタンスだけで使える組み込み関数を登録する
+// 同じ名前の組み込み関数があれば、このインスタンスでは登録した関数を使う
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.env.Set(name, &object.Builtin{Fn: fn})
+}
+
+// 評価結果をGoの戻り値にする
+func result(ctx context.Context, obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errObj
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
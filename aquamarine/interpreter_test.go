@@ -0,0 +1,362 @@
+package aquamarine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2 * 3", int64(7)},
+		{"1.5 * 2", float64(3)},
+		{`"Hello " + "World"`, "Hello World"},
+		{"[1, 2 * 2]", []interface{}{int64(1), int64(4)}},
+		{`{"a": 1}["a"]`, int64(1)},
+		{"let x = 1;", nil},
+		{"if (false) { 1 }", nil},
+	}
+
+	for _, tt := range tests {
+		interp := New()
+		obj, err := interp.Eval(context.Background(), tt.input)
+		if err != nil {
+			t.Fatalf("Eval(%q) returned error: %s", tt.input, err)
+		}
+		testValue(t, FromObject(obj), tt.expected)
+	}
+}
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if _, err := interp.Eval(ctx, "let count = 1; let inc = fn(n) { count + n };"); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	obj, err := interp.Eval(ctx, "inc(2)")
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(3))
+
+	count, ok := interp.GetGlobal("count")
+	if !ok {
+		t.Fatalf("GetGlobal(count) not found")
+	}
+	testValue(t, FromObject(count), int64(1))
+	if _, ok := interp.GetGlobal("missing"); ok {
+		t.Errorf("GetGlobal(missing) found")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	_, err := interp.Eval(ctx, "let = 1;")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *SyntaxError. got=%T (%v)", err, err)
+	}
+	if len(syntaxErr.Diagnostics) != 1 || syntaxErr.Diagnostics[0].Pos.Filename != "<eval>" {
+		t.Errorf("wrong diagnostics. got=%v", syntaxErr.Diagnostics)
+	}
+
+	_, err = interp.Eval(ctx, "1 / 0")
+	var runtimeErr *object.Error
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *object.Error. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Message != "division by zero" {
+		t.Errorf("wrong message. got=%q", runtimeErr.Message)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := interp.Eval(canceled, "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if _, err := interp.Eval(ctx, `let sum = fn(xs) { let total = 0; for (x in xs) { total += x; } total };
+let notFn = 1;`); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+
+	obj, err := interp.Call("sum", []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Call(sum) returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(6))
+
+	obj, err = interp.Call("len", "abc")
+	if err != nil {
+		t.Fatalf("Call(len) returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(3))
+
+	tests := []struct {
+		name string
+		args []interface{}
+	}{
+		{"missing", nil},
+		{"notFn", nil},
+		{"sum", []interface{}{1, 2}},
+		{"sum", []interface{}{make(chan int)}},
+	}
+	for _, tt := range tests {
+		if _, err := interp.Call(tt.name, tt.args...); err == nil {
+			t.Errorf("Call(%s, %v) expected error", tt.name, tt.args)
+		}
+	}
+}
+
+func TestSetGlobalAndBuiltins(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if err := interp.SetGlobal("config", map[string]interface{}{"limit": 3, "names": []string{"a", "b"}}); err != nil {
+		t.Fatalf("SetGlobal returned error: %s", err)
+	}
+	if err := interp.SetGlobal("bad", make(chan int)); err == nil {
+		t.Errorf("SetGlobal(chan) expected error")
+	}
+
+	interp.RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		n := args[0].(*object.Integer)
+		return &object.Integer{Value: n.Value * 2}
+	})
+	// 組み込み関数を上書きできる
+	interp.RegisterBuiltin("len", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: -1}
+	})
+
+	obj, err := interp.Eval(ctx, `double(config["limit"]) + len(config["names"])`)
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(5))
+}
+
+func TestIsolation(t *testing.T) {
+	ctx := context.Background()
+	a, b := New(), New()
+
+	a.SetGlobal("x", 1)
+	a.RegisterBuiltin("host", func(args ...object.Object) object.Object {
+		return &object.String{Value: "a"}
+	})
+	if _, err := a.Eval(ctx, "let y = 2;"); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+
+	for _, name := range []string{"x", "y", "host"} {
+		if _, ok := b.GetGlobal(name); ok {
+			t.Errorf("%s leaked into another interpreter", name)
+		}
+	}
+	if _, err := b.Eval(ctx, "host()"); err == nil {
+		t.Errorf("host function leaked into another interpreter")
+	}
+	// 元の組み込み関数はどちらでも使える
+	obj, err := b.Eval(ctx, `len("abc")`)
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(3))
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	interp := New()
+	interp.SetLimits(object.Limits{MaxSteps: 1000})
+
+	if _, err := interp.Eval(ctx, "let spin = fn() { while (true) {} };"); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+
+	_, err := interp.Eval(ctx, "spin()")
+	var errObj *object.Error
+	if !errors.As(err, &errObj) || errObj.Message != "step limit exceeded: 1000" {
+		t.Errorf("expected step limit error. got=%v", err)
+	}
+
+	// 手数は呼び出しのたびに数え直す
+	if _, err := interp.Eval(ctx, "let i = 0; while (i < 10) { i += 1; }"); err != nil {
+		t.Errorf("Eval returned error: %s", err)
+	}
+	if _, err := interp.Call("spin"); !errors.As(err, &errObj) {
+		t.Errorf("expected step limit error from Call. got=%v", err)
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	interp := New()
+	if _, err := interp.Eval(context.Background(), "let spin = fn() { while (true) {} };"); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := interp.Eval(ctx, "spin()"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Eval: expected DeadlineExceeded. got=%v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := interp.CallContext(ctx, "spin"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CallContext: expected DeadlineExceeded. got=%v", err)
+	}
+
+	// 取り消した後も同じインスタンスを使える
+	obj, err := interp.Eval(context.Background(), "1 + 2")
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(3))
+}
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	interp := New()
+
+	// 既定では外の世界を使う組み込み関数を呼べない
+	_, err := interp.Eval(ctx, `output("hi")`)
+	var errObj *object.Error
+	if !errors.As(err, &errObj) || errObj.Message != "permission denied: output requires the io capability" {
+		t.Fatalf("expected permission error. got=%v", err)
+	}
+
+	var out strings.Builder
+	interp.SetOutput(&out)
+	interp.SetClock(func() time.Time { return time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC) })
+	interp.Grant(object.CapIO | object.CapTime)
+
+	if _, err := interp.Eval(ctx, `output("today: " + now())`); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	if out.String() != "today: 2000-01-02\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+	if _, err := interp.Call("now"); err != nil {
+		t.Errorf("Call returned error: %s", err)
+	}
+
+	interp.Revoke(object.CapTime)
+	if _, err := interp.Call("now"); !errors.As(err, &errObj) {
+		t.Errorf("expected permission error after Revoke. got=%v", err)
+	}
+
+	// 許可は他のインスタンスに影響しない
+	if _, err := New().Eval(ctx, `output("hi")`); err == nil {
+		t.Errorf("grant leaked into another interpreter")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	ctx := context.Background()
+	a, b := New(), New()
+	a.Grant(object.CapProc)
+	b.Grant(object.CapProc)
+	a.SetArgs("foo", "bar")
+
+	obj, err := a.Eval(ctx, "argv(1)")
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), "bar")
+
+	// 引数は他のインスタンスに影響しない
+	obj, err = b.Eval(ctx, "argc()")
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(0))
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	src := `let calls = 0; export let square = fn(x) { calls += 1; x * x };`
+	if err := os.WriteFile(filepath.Join(dir, "mathx.aq"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	interp := New()
+	if _, err := interp.Eval(ctx, `import "mathx.aq" as m;`); err == nil {
+		t.Errorf("import should be unavailable before SetModulePath")
+	}
+
+	interp.SetModulePath(dir)
+	obj, err := interp.Eval(ctx, `import "mathx.aq" as m; m.square(7)`)
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(49))
+
+	// モジュールの関数もCallで呼べる
+	if _, err := interp.Eval(ctx, `let sq = m.square;`); err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	obj, err = interp.Call("sq", 3)
+	if err != nil {
+		t.Fatalf("Call returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(9))
+}
+
+func TestModuleSandbox(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	if err := os.Mkdir(lib, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{
+		"secret.aq":     `export let x = 1;`,
+		"lib/inside.aq": `export let x = 2;`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	interp := New()
+	interp.SetModulePath(lib)
+
+	// 権限が無ければ検索パスの外は読めない
+	for _, path := range []string{"../secret.aq", filepath.Join(dir, "secret.aq"), filepath.Join(lib, "inside.aq")} {
+		_, err := interp.Eval(ctx, fmt.Sprintf("import %q as m;", path))
+		var errObj *object.Error
+		if !errors.As(err, &errObj) || !strings.HasPrefix(errObj.Message, "permission denied: ") {
+			t.Errorf("import %q: expected permission error. got=%v", path, err)
+		}
+	}
+	obj, err := interp.Eval(ctx, `import "inside.aq" as m; m.x`)
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(2))
+
+	interp.Grant(object.CapFS)
+	obj, err = interp.Eval(ctx, `import "../secret.aq" as s; s.x`)
+	if err != nil {
+		t.Fatalf("Eval returned error: %s", err)
+	}
+	testValue(t, FromObject(obj), int64(1))
+}
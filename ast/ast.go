@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 
 	"github.com/takeru-a/golang_interpreterlang/token"
@@ -15,6 +16,10 @@ type Node interface {
 
 	// nodeの内容を文字列で返す
 	String() string
+
+	// ソース上の範囲 (開始位置と終了位置)
+	Pos() token.Position
+	End() token.Position
 }
 
 // 文のインターフェース
@@ -42,6 +47,13 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 
 // 式の文字列表現を返す
 func (es *ExpressionStatement) String() string {
@@ -61,6 +73,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // プログラムの文字列表現を返す
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -80,6 +106,16 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Name != nil {
+		return ls.Name.End()
+	}
+	return ls.Token.End
+}
 
 // let x = 5;
 func (ls *LetStatement) String() string {
@@ -105,6 +141,13 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 
 // return 5;
 func (rs *ReturnStatement) String() string {
@@ -117,6 +160,132 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// while文の構文解析
+type WhileStatement struct {
+	Token     token.Token // 'while'トークン
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position {
+	if ws.Body != nil {
+		return ws.Body.End()
+	}
+	return ws.Token.End
+}
+
+// while (x < 10) { ... }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// for文の構文解析
+type ForStatement struct {
+	Token    token.Token // 'for'トークン
+	Variable *Indetifier // 要素を束縛する変数
+	Iterable Expression  // 配列・ハッシュ・文字列
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position {
+	if fs.Body != nil {
+		return fs.Body.End()
+	}
+	return fs.Token.End
+}
+
+// for (x in xs) { ... }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// break文
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// continue文
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+// import文 import "path/to/util.aq" as util
+type ImportStatement struct {
+	Token token.Token    // 'import'トークン
+	Path  *StringLiteral // モジュールのパス
+	Name  *Indetifier    // モジュールを束縛する名前
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Name != nil {
+		return is.Name.End()
+	}
+	if is.Path != nil {
+		return is.Path.End()
+	}
+	return is.Token.End
+}
+func (is *ImportStatement) String() string {
+	return "import " + strconv.Quote(is.Path.Value) + " as " + is.Name.String() + ";"
+}
+
+// export文 export let name = value;
+// モジュールの外から util.name で参照できる
+type ExportStatement struct {
+	Token     token.Token // 'export'トークン
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position {
+	if es.Statement != nil {
+		return es.Statement.End()
+	}
+	return es.Token.End
+}
+func (es *ExportStatement) String() string {
+	return "export " + es.Statement.String()
+}
+
 // 式の構文解析
 type Indetifier struct {
 	Token token.Token
@@ -127,6 +296,8 @@ func (i *Indetifier) expressionNode() {}
 
 // プログラムが読み取る用
 func (i *Indetifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Indetifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Indetifier) End() token.Position  { return i.Token.End }
 
 // 人間が読む用
 func (i *Indetifier) String() string { return i.Value }
@@ -140,6 +311,8 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 
 // 前置構文解析
 type PrefixExpression struct {
@@ -150,6 +323,13 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -171,6 +351,18 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -183,6 +375,18 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// 浮動小数点数リテラル
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+
 // 真偽値リテラルの構文解析
 type Boolean struct {
 	Token token.Token
@@ -192,6 +396,8 @@ type Boolean struct {
 func(b *Boolean) expressionNode() {}
 func(b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func(b *Boolean) String() string { return b.Token.Literal }
+func(b *Boolean) Pos() token.Position { return b.Token.Pos }
+func(b *Boolean) End() token.Position { return b.Token.End }
 
 // if文の構文解析
 type IfExpression struct {
@@ -203,6 +409,16 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return ie.Token.End
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -220,12 +436,20 @@ func (ie *IfExpression) String() string {
 }
 
 type BlockStatement struct {
-	Token token.Token
+	Token token.Token // '{'トークン
 	Statements []Statement
+	Rbrace token.Token // '}'トークン
 }
 
 func (bs *BlockStatement) expressionNode() {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.End.IsValid() {
+		return bs.Rbrace.End
+	}
+	return bs.Token.End
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -240,10 +464,18 @@ type FunctionLiteral struct {
 	Token token.Token
 	Parameters []*Indetifier
 	Body *BlockStatement
+	Name string // letで束縛された名前 (無ければ空)
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -263,13 +495,26 @@ func (fl *FunctionLiteral) String() string {
 
 // 呼び出し式
 type CallExpression struct {
-	Token token.Token
+	Token token.Token // '('トークン
 	Function Expression // Idetifier or FunctionLiteral
 	Arguments []Expression
+	Rparen token.Token // ')'トークン
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.End.IsValid() {
+		return ce.Rparen.End
+	}
+	return ce.Token.End
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -286,6 +531,167 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// 配列リテラル
+type ArrayLiteral struct {
+	Token token.Token // '['トークン
+	Elements []Expression
+	Rbracket token.Token // ']'トークン
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position {
+	if al.Rbracket.End.IsValid() {
+		return al.Rbracket.End
+	}
+	return al.Token.End
+}
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// 添字式 array[index]
+type IndexExpression struct {
+	Token token.Token // '['トークン
+	Left Expression
+	Index Expression
+	Rbracket token.Token // ']'トークン
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbracket.End.IsValid() {
+		return ie.Rbracket.End
+	}
+	return ie.Token.End
+}
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// モジュールの名前の参照 util.name
+type MemberExpression struct {
+	Token token.Token // '.'トークン
+	Left  Expression
+	Name  *Indetifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position {
+	if me.Left != nil {
+		return me.Left.Pos()
+	}
+	return me.Token.Pos
+}
+func (me *MemberExpression) End() token.Position {
+	if me.Name != nil {
+		return me.Name.End()
+	}
+	return me.Token.End
+}
+func (me *MemberExpression) String() string {
+	return me.Left.String() + "." + me.Name.String()
+}
+
+// 代入式 x = value, x += value, array[index] = value
+type AssignExpression struct {
+	Token    token.Token // '='や'+='などのトークン
+	Target   Expression  // 識別子または添字式
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position {
+	if ae.Target != nil {
+		return ae.Target.Pos()
+	}
+	return ae.Token.Pos
+}
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// ハッシュのキーと値の組
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// ハッシュリテラル {key: value, ...}
+type HashLiteral struct {
+	Token token.Token // '{'トークン
+	Pairs []HashPair  // 記述順
+	Rbrace token.Token // '}'トークン
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position {
+	if hl.Rbrace.End.IsValid() {
+		return hl.Rbrace.End
+	}
+	return hl.Token.End
+}
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 // 文字列
 type StringLiteral struct {
 	Token token.Token
@@ -295,3 +701,39 @@ type StringLiteral struct {
 func (st StringLiteral) expressionNode() {}
 func (st StringLiteral) TokenLiteral() string { return st.Token.Literal }
 func (st StringLiteral) String() string { return st.Token.Literal }
+func (st StringLiteral) Pos() token.Position { return st.Token.Pos }
+func (st StringLiteral) End() token.Position { return st.Token.End }
+
+// 埋め込み式を含む文字列 "Hello ${name}!"
+// Literals[0], Exprs[0], Literals[1], ... の順に並ぶ (len(Literals) == len(Exprs)+1)
+type InterpolatedString struct {
+	Token    token.Token  // 最初のTEMPLATE_PARTトークン
+	Literals []string      // 文字列の部分 (エスケープ解釈済み)
+	Parts    []token.Token // 文字列の部分のトークン (Literalsと同じ順で、最後はEndToken)
+	Exprs    []Expression  // 埋め込み式
+	EndToken token.Token  // TEMPLATE_ENDトークン
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position {
+	if is.EndToken.End.IsValid() {
+		return is.EndToken.End
+	}
+	return is.Token.End
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for i, lit := range is.Literals {
+		out.WriteString(lit)
+		if i < len(is.Exprs) {
+			out.WriteString("${" + is.Exprs[i].String() + "}")
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 	"github.com/takeru-a/golang_interpreterlang/token"
 )
@@ -25,4 +26,50 @@ func TestString(t *testing.T){
 	if program.String() != "let myVar = anotherVar;"{
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
-}
\ No newline at end of file
+}
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Indetifier {
+		return &Indetifier{Token: token.Token{Type: token.INDENT, Literal: name}, Value: name}
+	}
+	// let f = fn(x) { g(x) }; h
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Indetifier{ident("x")},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: &CallExpression{Function: ident("g"), Arguments: []Expression{ident("x")}}},
+						},
+					},
+				},
+			},
+			&ExpressionStatement{Expression: ident("h")},
+		},
+	}
+
+	var names []string
+	Inspect(program, func(n Node) bool {
+		if i, ok := n.(*Indetifier); ok {
+			names = append(names, i.Value)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "f x g x h" {
+		t.Errorf("wrong order. got=%q", names)
+	}
+
+	// 関数の中はたどらない
+	names = nil
+	Inspect(program, func(n Node) bool {
+		if i, ok := n.(*Indetifier); ok {
+			names = append(names, i.Value)
+		}
+		_, isFn := n.(*FunctionLiteral)
+		return !isFn
+	})
+	if strings.Join(names, " ") != "f h" {
+		t.Errorf("wrong names when skipping functions. got=%q", names)
+	}
+}
@@ -0,0 +1,122 @@
+package ast
+
+// 構文木を深さ優先でたどり、各ノードでfを呼ぶ
+// fがfalseを返したときはそのノードの子はたどらない
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+
+	case *LetStatement:
+		inspectIdent(n.Name, f)
+		inspectExpr(n.Value, f)
+
+	case *ReturnStatement:
+		inspectExpr(n.ReturnValue, f)
+
+	case *ExpressionStatement:
+		inspectExpr(n.Expression, f)
+
+	case *WhileStatement:
+		inspectExpr(n.Condition, f)
+		inspectBlock(n.Body, f)
+
+	case *ForStatement:
+		inspectIdent(n.Variable, f)
+		inspectExpr(n.Iterable, f)
+		inspectBlock(n.Body, f)
+
+	case *ImportStatement:
+		if n.Path != nil {
+			Inspect(n.Path, f)
+		}
+		inspectIdent(n.Name, f)
+
+	case *ExportStatement:
+		if n.Statement != nil {
+			Inspect(n.Statement, f)
+		}
+
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+
+	case *PrefixExpression:
+		inspectExpr(n.Right, f)
+
+	case *InfixExpression:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Right, f)
+
+	case *AssignExpression:
+		inspectExpr(n.Target, f)
+		inspectExpr(n.Value, f)
+
+	case *IfExpression:
+		inspectExpr(n.Condition, f)
+		inspectBlock(n.Consequence, f)
+		inspectBlock(n.Alternative, f)
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			inspectIdent(p, f)
+		}
+		inspectBlock(n.Body, f)
+
+	case *CallExpression:
+		inspectExpr(n.Function, f)
+		for _, a := range n.Arguments {
+			inspectExpr(a, f)
+		}
+
+	case *IndexExpression:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Index, f)
+
+	case *MemberExpression:
+		inspectExpr(n.Left, f)
+		inspectIdent(n.Name, f)
+
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			inspectExpr(e, f)
+		}
+
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			inspectExpr(pair.Key, f)
+			inspectExpr(pair.Value, f)
+		}
+
+	case *InterpolatedString:
+		for _, e := range n.Exprs {
+			inspectExpr(e, f)
+		}
+	}
+}
+
+// 途中までしか解析できなかったノードはnilのことがある
+func inspectExpr(e Expression, f func(Node) bool) {
+	if e != nil {
+		Inspect(e, f)
+	}
+}
+
+func inspectIdent(i *Indetifier, f func(Node) bool) {
+	if i != nil {
+		Inspect(i, f)
+	}
+}
+
+func inspectBlock(b *BlockStatement, f func(Node) bool) {
+	if b != nil {
+		Inspect(b, f)
+	}
+}
@@ -0,0 +1,98 @@
+package diag
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+// 重大度
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// 字句解析・構文解析などで見つかった問題
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position // 開始位置
+	End      token.Position // 終了位置 (問題のある範囲の直後)
+	Message  string
+	Hint     string // 直し方の手がかり (無ければ空)
+	Code     string // 問題の種類 (lintの規則名など、無ければ空)
+}
+
+// "file:line:col: message" 形式の文字列表現
+func (d Diagnostic) String() string {
+	return d.Pos.String() + ": " + d.Message
+}
+
+// 重大度とヒントを付けた表示
+//
+//	main.aq:1:1: error: unexpected INDENT after statement
+//	    hint: did you mean `let`?
+//	main.aq:3:5: warning: x declared and not used [unused-variable]
+func (d Diagnostic) Format() string {
+	var out strings.Builder
+
+	out.WriteString(d.Pos.String() + ": " + d.Severity.String() + ": " + d.Message)
+	if d.Code != "" {
+		out.WriteString(" [" + d.Code + "]")
+	}
+	if d.Hint != "" {
+		out.WriteString("\n    hint: " + d.Hint)
+	}
+
+	return out.String()
+}
+
+// 文字列表現 (String) の一覧
+func Strings(diagnostics []Diagnostic) []string {
+	msgs := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		msgs[i] = d.String()
+	}
+	return msgs
+}
+
+// JSONでの表現 (行・列は1始まり)
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"`
+}
+
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDiagnostic{
+		File:      d.Pos.Filename,
+		Line:      d.Pos.Line,
+		Column:    d.Pos.Column,
+		EndLine:   d.End.Line,
+		EndColumn: d.End.Column,
+		Severity:  d.Severity.String(),
+		Code:      d.Code,
+		Message:   d.Message,
+		Hint:      d.Hint,
+	})
+}
@@ -0,0 +1,63 @@
+package diag
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+func TestFormat(t *testing.T) {
+	pos := token.Position{Filename: "main.aq", Line: 2, Column: 5}
+
+	tests := []struct {
+		diagnostic     Diagnostic
+		expectedString string
+		expectedFormat string
+	}{
+		{
+			Diagnostic{Severity: Error, Pos: pos, Message: "unexpected INDENT after statement", Hint: "did you mean `let`?"},
+			"main.aq:2:5: unexpected INDENT after statement",
+			"main.aq:2:5: error: unexpected INDENT after statement\n    hint: did you mean `let`?",
+		},
+		{
+			Diagnostic{Severity: Warning, Pos: pos, Message: "unused variable x"},
+			"main.aq:2:5: unused variable x",
+			"main.aq:2:5: warning: unused variable x",
+		},
+		{
+			Diagnostic{Severity: Warning, Pos: pos, Message: "x declared and not used", Code: "unused-variable"},
+			"main.aq:2:5: x declared and not used",
+			"main.aq:2:5: warning: x declared and not used [unused-variable]",
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.diagnostic.String() != tt.expectedString {
+			t.Errorf("wrong String. want=%q, got=%q", tt.expectedString, tt.diagnostic.String())
+		}
+		if tt.diagnostic.Format() != tt.expectedFormat {
+			t.Errorf("wrong Format. want=%q, got=%q", tt.expectedFormat, tt.diagnostic.Format())
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	d := Diagnostic{
+		Severity: Error,
+		Pos:      token.Position{Filename: "main.aq", Line: 2, Column: 5},
+		End:      token.Position{Filename: "main.aq", Line: 2, Column: 8},
+		Message:  "undefined: foo",
+		Code:     "undefined-name",
+	}
+
+	b, err := json.Marshal([]Diagnostic{d})
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %s", err)
+	}
+
+	expected := `[{"file":"main.aq","line":2,"column":5,"endLine":2,"endColumn":8,"severity":"error","code":"undefined-name","message":"undefined: foo"}]`
+	if string(b) != expected {
+		t.Errorf("wrong JSON.\nwant=%s\ngot=%s", expected, b)
+	}
+}
@@ -2,7 +2,12 @@ package evaluator
 
 import (
 	"fmt"
-	"time"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/takeru-a/golang_interpreterlang/object"
 )
@@ -16,7 +21,11 @@ var builtins = map[string]*object.Builtin {
 			
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -24,9 +33,12 @@ var builtins = map[string]*object.Builtin {
 	},
 
 	"output": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Name:       "output",
+		Capability: object.CapIO,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			w := host.Output()
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(w, object.Display(arg))
 			}
 
 			return NULLSTRING
@@ -34,10 +46,526 @@ var builtins = map[string]*object.Builtin {
 	},
 
 	"now": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Name:       "now",
+		Capability: object.CapTime,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
 
-			now := time.Now()
+			now := host.Time()
 			return &object.String{Value: now.Format("2006-01-02")}
 		},
 	},
-}
\ No newline at end of file
+
+	"first": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+			return NULL
+		},
+	},
+
+	"last": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return NULL
+		},
+	},
+
+	// 先頭以外の要素を持つ新しい配列
+	"rest": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+			return NULL
+		},
+	},
+
+	// 末尾に要素を追加した新しい配列
+	"push": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			length := len(arr.Elements)
+			newElements := make([]object.Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &object.Array{Elements: newElements}
+		},
+	},
+
+	// slice(array, start[, end]) 負の添字は末尾から数える
+	"slice": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			var length int64
+			var runes []rune
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length = int64(len(arg.Elements))
+			case *object.String:
+				runes = []rune(arg.Value)
+				length = int64(len(runes))
+			default:
+				return newError("argument to `slice` must be ARRAY or STRING, got %s", args[0].Type())
+			}
+
+			bounds := []int64{0, length}
+			for i, arg := range args[1:] {
+				n, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("slice index must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = clampIndex(n.Value, length)
+			}
+
+			start, end := bounds[0], bounds[1]
+			if start > end {
+				start = end
+			}
+
+			// 文字列は文字単位で切り出す
+			if args[0].Type() == object.STRING_OBJ {
+				return &object.String{Value: string(runes[start:end])}
+			}
+
+			arr := args[0].(*object.Array)
+			newElements := make([]object.Object, end-start)
+			copy(newElements, arr.Elements[start:end])
+
+			return &object.Array{Elements: newElements}
+		},
+	},
+
+	// 配列を連結した新しい配列
+	"concat": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			newElements := []object.Object{}
+			for _, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to `concat` must be ARRAY, got %s", arg.Type())
+				}
+				newElements = append(newElements, arr.Elements...)
+			}
+
+			return &object.Array{Elements: newElements}
+		},
+	},
+
+	"keys": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, pair := range hash.Items() {
+				elements = append(elements, pair.Key)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"values": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `values` must be HASH, got %s", args[0].Type())
+			}
+
+			elements := []object.Object{}
+			for _, pair := range hash.Items() {
+				elements = append(elements, pair.Value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	"has": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `has` must be HASH, got %s", args[0].Type())
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, ok = hash.Lookup(key)
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+
+	// キーを取り除いた新しいハッシュ
+	"delete": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			newHash := copyHash(hash)
+			newHash.Delete(key.HashKey())
+			return newHash
+		},
+	},
+
+	// ハッシュを統合した新しいハッシュ (同じキーは後の値を優先)
+	"merge": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			newHash := object.NewHash()
+			for _, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument to `merge` must be HASH, got %s", arg.Type())
+				}
+				for _, pair := range hash.Items() {
+					newHash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+			}
+			return newHash
+		},
+	},
+
+	"argc": &object.Builtin{
+		Name:       "argc",
+		Capability: object.CapProc,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			return &object.Integer{Value: int64(len(host.Args))}
+		},
+	},
+
+	"argv": &object.Builtin{
+		Name:       "argv",
+		Capability: object.CapProc,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			idx, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `argv` must be INTEGER, got %s", args[0].Type())
+			}
+			if idx.Value < 0 || idx.Value >= int64(len(host.Args)) {
+				return newError("argv index out of range: %d (argc=%d)", idx.Value, len(host.Args))
+			}
+
+			return &object.String{Value: host.Args[idx.Value]}
+		},
+	},
+
+	// 環境変数の値 (無ければnull)
+	"getenv": &object.Builtin{
+		Name:       "getenv",
+		Capability: object.CapEnv,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `getenv` must be STRING, got %s", args[0].Type())
+			}
+			value, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return NULL
+			}
+			return &object.String{Value: value}
+		},
+	},
+
+	// ファイルの内容を文字列で読む
+	"readFile": &object.Builtin{
+		Name:       "readFile",
+		Capability: object.CapFS,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `readFile` must be STRING, got %s", args[0].Type())
+			}
+			data, err := os.ReadFile(path.Value)
+			if err != nil {
+				return newError("readFile: %s", err)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+
+	// 文字列をファイルに書く (ファイルがあれば置き換える)
+	"writeFile": &object.Builtin{
+		Name:       "writeFile",
+		Capability: object.CapFS,
+		HostFn: func(host *object.Host, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `writeFile` must be STRING, got %s", args[0].Type())
+			}
+			data, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `writeFile` must be STRING, got %s", args[1].Type())
+			}
+			if err := os.WriteFile(path.Value, []byte(data.Value), 0644); err != nil {
+				return newError("writeFile: %s", err)
+			}
+			return NULLSTRING
+		},
+	},
+
+	// 文字列のUTF-8のバイト列 (整数の配列)
+	"bytes": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `bytes` must be STRING, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = &object.Integer{Value: int64(str.Value[i])}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+
+	// 文字列のUTF-8のバイト数 (lenは文字数)
+	"byteLen": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `byteLen` must be STRING, got %s", args[0].Type())
+			}
+
+			return &object.Integer{Value: int64(len(str.Value))}
+		},
+	},
+
+	"int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				return floatToInteger(math.Trunc(arg.Value))
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError("could not convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: value}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	"float": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("could not convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+
+	// round(x) は整数、round(x, 桁数) は小数点以下を桁数で丸めた浮動小数点数を返す
+	"round": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if !isNumber(args[0]) {
+				return newError("argument to `round` must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+
+			if len(args) == 1 {
+				if args[0].Type() == object.INTEGER_OBJ {
+					return args[0]
+				}
+				return floatToInteger(math.Round(toFloat(args[0])))
+			}
+
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `round` must be INTEGER, got %s", args[1].Type())
+			}
+			scale := math.Pow(10, float64(digits.Value))
+			return &object.Float{Value: math.Round(toFloat(args[0])*scale) / scale}
+		},
+	},
+
+	"floor": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return roundBuiltin("floor", math.Floor, args)
+		},
+	},
+
+	"ceil": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return roundBuiltin("ceil", math.Ceil, args)
+		},
+	},
+}
+
+// floor, ceilの共通処理
+func roundBuiltin(name string, fn func(float64) float64, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return floatToInteger(fn(arg.Value))
+	default:
+		return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+	}
+}
+
+// 浮動小数点数を整数に変換する (整数の範囲外ならエラー)
+func floatToInteger(f float64) object.Object {
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return newError("cannot convert %s to INTEGER", (&object.Float{Value: f}).Inspect())
+	}
+	return &object.Integer{Value: int64(f)}
+}
+
+// 負の添字を末尾からの位置に直し、[0, length]の範囲に収める
+func clampIndex(idx, length int64) int64 {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0
+	}
+	if idx > length {
+		return length
+	}
+	return idx
+}
+
+// 組み込み関数の名前一覧 (名前順)
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 名前から組み込み関数を探す
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// ハッシュの浅いコピー
+func copyHash(hash *object.Hash) *object.Hash {
+	newHash := object.NewHash()
+	for _, pair := range hash.Items() {
+		newHash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+	}
+	return newHash
+}
@@ -2,10 +2,12 @@ package evaluator
 
 import (
 	"fmt"
-	"strconv"
+	"math"
+	"strings"
 
 	"github.com/takeru-a/golang_interpreterlang/ast"
 	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/token"
 )
 
 var (
@@ -13,9 +15,32 @@ var (
 	NULLSTRING = &object.NULLSTRING{}
 	TRUE = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+	BREAK = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	x := execution(env)
+
+	var result object.Object
+	if err := step(x); err != nil {
+		result = err
+	} else {
+		result = eval(node, env)
+		if err := checkSize(x, result); err != nil {
+			result = err
+		}
+	}
+
+	// 位置情報の無いエラーには、エラーを起こしたノードの位置を付ける
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 
 	switch node := node.(type) {
 
@@ -27,6 +52,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -39,6 +67,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalPrefixExpression(node.Operator, right)
 	
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -62,19 +94,47 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 	
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ImportStatement:
+		module := evalImportStatement(node, env)
+		if isError(module) {
+			return module
+		}
+		env.Set(node.Name.Value, module)
+
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+
 	case *ast.Indetifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Name: node.Name}
 	
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -87,16 +147,81 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, node.Pos(), execution(env))
 	
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
 	}
 
 	return nil
 }
 
+// 以下はvmと共有する演算
+
+// 前置演算子を適用する
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// 中置演算子を適用する
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// 添字演算子を適用する
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// 添字の位置に値を代入する
+func SetIndex(left, index, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// 複合代入演算子 ("+="など) に対応する中置演算子を返す
+// 単純な代入 ("=") なら空文字列を返す
+func AssignOperator(operator string) string {
+	return strings.TrimSuffix(operator, "=")
+}
+
+// 条件式として真かどうか
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// for文で繰り返す要素
+func IterableElements(obj object.Object) ([]object.Object, *object.Error) {
+	return iterableElements(obj)
+}
+
+// 位置の無い呼び出し (Goのプログラムからの呼び出し)
+var noPos token.Position
+
 // 文を評価
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
@@ -123,7 +248,8 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -168,12 +294,14 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 // -の評価
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 // 中置式
@@ -182,13 +310,17 @@ func evalInfixExpression(
 	left, right object.Object,
 ) object.Object {
 	switch {
+	// 等しさはどの型でもobject.Equalsで比べる
+	case operator == "==":
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
-	case operator == "==" && left.Type() != object.STRING_OBJ && right.Type() !=  object.STRING_OBJ:
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
-	case (left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ) || (left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ):
+	case isNumber(left) && isNumber(right):
+		// 整数と浮動小数点数の演算は浮動小数点数にそろえる
+		return evalFloatInfixExpression(operator, left, right)
+	case (left.Type() == object.STRING_OBJ && isNumber(right)) || (isNumber(left) && right.Type() == object.STRING_OBJ):
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
@@ -199,6 +331,25 @@ func evalInfixExpression(
 	}
 }
 
+// && と || の評価
+// 左辺で結果が決まるときは右辺を評価しない
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 // 計算式の評価
 func evalIntegerInfixExpression(
 	operator string,
@@ -215,20 +366,97 @@ func evalIntegerInfixExpression(
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		return &object.Integer{Value: intPow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 浮動小数点数の計算式の評価
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// 整数のべき乗 (expは0以上)
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// 整数か浮動小数点数か
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// 数値を浮動小数点数に変換する
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 // ifの評価
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
@@ -238,14 +466,22 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return nullIfNil(Eval(ie.Consequence, env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return nullIfNil(Eval(ie.Alternative, env))
 	} else {
 		return NULL
 	}
 }
 
+// 値を持たないブロック (最後が let 文など) の値はNULLにする
+func nullIfNil(obj object.Object) object.Object {
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -300,16 +536,53 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// 埋め込み式を含む文字列
+// 埋め込み式の値はobject.Displayで文字列にする
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for i, lit := range node.Literals {
+		out.WriteString(lit)
+		if i >= len(node.Exprs) {
+			break
+		}
+
+		val := Eval(node.Exprs[i], env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(object.Display(val))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 // 呼び出し式
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// posは呼び出した位置 (エラーの呼び出し履歴に使う)、xは呼び出し元の実行状態
+func applyFunction(fn object.Object, args []object.Object, pos token.Position, x *object.Execution) object.Object {
 	switch fn:= fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments to %s: want=%d, got=%d",
+				fn.DisplayName(), len(fn.Parameters), len(args))
+		}
+		if err := enter(x); err != nil {
+			return err
+		}
+		defer leave(x)
+
 		extendedEnv := extendFunctionEnv(fn, args)
+		// 関数を定義したときではなく、呼び出したときの実行状態を使う
+		extendedEnv.SetExecution(x)
 		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			errObj.Stack = append(errObj.Stack, object.StackFrame{Function: fn.DisplayName(), Pos: pos})
+			return errObj
+		}
+		return nullIfNil(unwrapReturnValue(evaluated))
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Call(x.Host, args...)
 
 	default:
 		return newError("not a function: %s", fn.Type())
@@ -329,7 +602,7 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue
+		return returnValue.Value
 	}
 
 	return obj
@@ -342,12 +615,12 @@ func evalStringInfixExpression(
 
 	var leftVal, rightVal string
 
-	if (left.Type() == object.INTEGER_OBJ){
-		leftVal = strconv.Itoa(int(left.(*object.Integer).Value))
+	if (isNumber(left)){
+		leftVal = object.Display(left)
 		rightVal = right.(*object.String).Value
 	
-	} else if (right.Type() == object.INTEGER_OBJ) {
-		rightVal = strconv.Itoa(int(right.(*object.Integer).Value))
+	} else if (isNumber(right)) {
+		rightVal = object.Display(right)
 		leftVal = left.(*object.String).Value
 
 	} else {
@@ -359,9 +632,309 @@ func evalStringInfixExpression(
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
 	
-	case "==":
-		return &object.Boolean{Value: leftVal == rightVal}
+	case "<", ">", "<=", ">=":
+		// 大小の比較は文字列同士だけ
+		if isNumber(left) || isNumber(right) {
+			return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		}
+		return evalStringComparison(operator, leftVal, rightVal)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
+
+// 文字列の大小の比較 (辞書順)
+func evalStringComparison(operator, leftVal, rightVal string) object.Object {
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	default:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	}
+}
+
+// 添字式の評価
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.ARRAY_OBJ:
+		return newError("array index must be INTEGER, got %s", index.Type())
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ:
+		return newError("string index must be INTEGER, got %s", index.Type())
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// 配列の添字 (負の添字は末尾から数える)
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	length := int64(len(arrayObject.Elements))
+
+	i := idx
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return newError("index out of range: %d (len=%d)", idx, length)
+	}
+
+	return arrayObject.Elements[i]
+}
+
+// 文字列の添字 (バイトではなく文字単位)
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(runes))
+
+	i := idx
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return newError("index out of range: %d (len=%d)", idx, length)
+	}
+
+	return &object.String{Value: string(runes[i])}
+}
+
+// ハッシュリテラルの評価
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+// ハッシュの添字 (キーが無ければNULL)
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Lookup(key)
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// 代入式の評価
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := AssignOperator(node.Operator)
+
+	switch target := node.Target.(type) {
+	case *ast.Indetifier:
+		var current object.Object
+		if operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			val = evalInfixExpression(operator, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		if _, ok := env.Assign(target.Value, val); !ok {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			val = evalInfixExpression(operator, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+
+		result := evalIndexAssignment(left, index, val)
+		if isError(result) {
+			return result
+		}
+		// ハッシュへの代入で要素が増える
+		if err := checkSize(execution(env), left); err != nil {
+			return err
+		}
+		return result
+
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// 配列・ハッシュの要素を書き換える
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		length := int64(len(left.Elements))
+
+		i := idx.Value
+		if i < 0 {
+			i += length
+		}
+		if i < 0 || i >= length {
+			return newError("index out of range: %d (len=%d)", idx.Value, length)
+		}
+
+		left.Elements[i] = val
+		return val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+		return val
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+// whileの評価
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(ws.Body, env)
+		if stop, ok := loopResult(result); !ok {
+			return stop
+		}
+	}
+}
+
+// forの評価
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	elements, err := iterableElements(iterable)
+	if err != nil {
+		err.Pos = fs.Iterable.Pos()
+		return err
+	}
+
+	for _, element := range elements {
+		env.Set(fs.Variable.Value, element)
+
+		result := Eval(fs.Body, env)
+		if stop, ok := loopResult(result); !ok {
+			return stop
+		}
+	}
+
+	return NULL
+}
+
+// ループ本体の結果を調べる
+// ループを続けるならtrue、止めるならループの値とfalseを返す
+func loopResult(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, true
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return NULL, false
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, false
+	}
+
+	return nil, true
+}
+
+// 繰り返しの要素 (配列は要素、ハッシュはキー、文字列は1文字ずつ)
+func iterableElements(obj object.Object) ([]object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Elements, nil
+	case *object.Hash:
+		keys := []object.Object{}
+		for _, pair := range obj.Items() {
+			keys = append(keys, pair.Key)
+		}
+		return keys, nil
+	case *object.String:
+		chars := []object.Object{}
+		for _, r := range obj.Value {
+			chars = append(chars, &object.String{Value: string(r)})
+		}
+		return chars, nil
+	default:
+		return nil, newError("not iterable: %s", obj.Type())
+	}
+}
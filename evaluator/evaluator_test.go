@@ -1,7 +1,13 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/takeru-a/golang_interpreterlang/lexer"
 	"github.com/takeru-a/golang_interpreterlang/object"
@@ -10,7 +16,7 @@ import (
 
 // ヘルパー関数
 func testEval(input string) object.Object {
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
@@ -34,6 +40,22 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+// 浮動小数点数
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 // 真偽値
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
@@ -83,6 +105,14 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
 	}
 
 	for _, tt := range tests {
@@ -116,6 +146,24 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"ab" >= "ab"`, true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"!(1 > 2) && 2 >= 2", true},
+		{"1 < 2 || 1 / 0", true},
+		{"1 > 2 && 1 / 0", false},
 	}
 
 	for _, tt := range tests {
@@ -321,6 +369,7 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; }; f() + 1", 2},
 	}
 
 	for _, tt := range tests {
@@ -421,3 +470,1051 @@ func TestBuiltinFunction(t *testing.T) {
 	}
 }
 
+
+// エラーの位置情報
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "test.aq:1:1"},
+		{"let a = 1;\nlet b = -true;", "test.aq:2:9"},
+		{"let f = fn(x) {\n  x + foo;\n};\nf(1);", "test.aq:2:7"},
+		{`len(1)`, "test.aq:1:1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("test.aq", tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%q, got=%q", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+// コマンドライン引数
+func TestScriptArgs(t *testing.T) {
+	host := &object.Host{Grants: object.CapProc, Args: []string{"foo", "bar"}}
+
+	tests := []struct {
+		input string
+		expected interface{}
+	}{
+		{`argc()`, 2},
+		{`argv(1)`, "bar"},
+		{`argv(2)`, "argv index out of range: 2 (argc=2)"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetHost(host)
+		evaluated := Eval(parser.New(lexer.New("", tt.input)).ParseProgram(), env)
+
+		switch obj := evaluated.(type) {
+		case *object.Integer:
+			testIntegerObject(t, obj, int64(tt.expected.(int)))
+		case *object.String:
+			if obj.Value != tt.expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, obj.Value)
+			}
+		case *object.Error:
+			if obj.Message != tt.expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, obj.Message)
+			}
+		default:
+			t.Errorf("unexpected object. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+
+	// 引数はホストごとに持つ
+	testIntegerObject(t, testEval(`argc()`), 0)
+}
+
+// 配列リテラル
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+// 配列の添字
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][3]", "index out of range: 3 (len=3)"},
+		{"[1, 2, 3][-4]", "index out of range: -4 (len=3)"},
+		{`[1, 2, 3]["a"]`, "array index must be INTEGER, got STRING"},
+		{"1[0]", "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+// 配列の組み込み関数
+func TestArrayBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	}{
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`slice([1, 2, 3, 4], 1)`, []int{2, 3, 4}},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int{2, 3}},
+		{`slice([1, 2, 3, 4], -2)`, []int{3, 4}},
+		{`slice([1, 2, 3, 4], 3, 1)`, []int{}},
+		{`slice([1, 2, 3, 4], 0, 10)`, []int{1, 2, 3, 4}},
+		{`slice([1], "a")`, "slice index must be INTEGER, got STRING"},
+		{`concat([1], [], [2, 3])`, []int{1, 2, 3}},
+		{`concat()`, []int{}},
+		{`concat([1], 2)`, "argument to `concat` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+// エラー
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+
+	return true
+}
+
+// ハッシュリテラル
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Get(expectedKey)
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+// ハッシュの添字
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1}`, "unusable as hash key: FUNCTION"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// ハッシュの組み込み関数
+func TestHashBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected interface{}
+	}{
+		{`len({"a": 1, "b": 2})`, 2},
+		{`keys({"b": 1, "a": 2, 3: 3})`, `["b", "a", 3]`},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`delete({"a": 1, "b": 2}, "a")`, `{"b": 2}`},
+		{`let h = {"a": 1}; delete(h, "a"); h`, `{"a": 1}`},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, `{"a": 1, "b": 3, "c": 4}`},
+		{`merge()`, "{}"},
+		{`keys([1])`, errorMessage("argument to `keys` must be HASH, got ARRAY")},
+		{`has({}, [1])`, errorMessage("unusable as hash key: ARRAY")},
+		{`merge({}, 1)`, errorMessage("argument to `merge` must be HASH, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil || object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// 期待するエラーメッセージ
+type errorMessage string
+
+// while文・for文
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"while (false) { 1 }", nil},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; }; let s = s + x; }; s", 8},
+		{`let s = ""; for (k in {"a": 1, "b": 2}) { let s = s + k; }; s`, "ab"},
+		{`let s = ""; for (c in "abc") { let s = c + s; }; s`, "cba"},
+		{"let s = 0; for (x in []) { let s = 1; }; s", 0},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } }; 0 }; f()", 20},
+		{
+			`let n = 0;
+			for (x in [1, 2, 3]) {
+				for (y in [1, 2, 3]) {
+					if (y > x) { break; }
+					let n = n + 1;
+				}
+			};
+			n`,
+			6,
+		},
+		{"for (x in 5) { x }", errorMessage("not iterable: INTEGER")},
+		{"while (true) { 1 + true; }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// 値を使うifの中のbreak・continueは構文エラーになり、文としてのifなら使える
+func TestLoopControlInValueIf(t *testing.T) {
+	for _, input := range []string{
+		"let i = 0; while (i < 5) { i += 1; let y = if (true) { break } else { 0 }; }; i",
+		"let r = []; for (i in [1, 2, 3]) { r = push(r, if (i == 2) { continue } else { i }) }; r",
+		"let i = 0; while (i < 5) { i += 1; 1 + if (true) { continue } else { 0 } }; i",
+	} {
+		p := parser.New(lexer.New("", input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 5) { i += 1; if (true) { break } else { 0 }; }; i", 1},
+		{"let r = []; for (i in [1, 2, 3]) { if (i == 2) { continue } else { r = push(r, i) } }; r[0] * 10 + r[1]", 13},
+		{"let i = 0; while (i < 5) { i += 1; if (true) { continue } else { 0 }; i += 10 }; i", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// 代入式
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let i = 0; while (i < 5) { i += 1; }; i", 5},
+		{"let x = 1; let f = fn() { x = 2; }; f(); x", 2},
+		{"let x = 1; let f = fn() { let x = 5; x = 2; }; f(); x", 1},
+		{
+			`let counter = fn() {
+				let n = 0;
+				fn() { n += 1; n }
+			};
+			let c = counter();
+			c(); c();
+			let d = counter();
+			d();
+			c()`,
+			3,
+		},
+		{"let a = [1, 2, 3]; a[0] = 10; a[-1] *= 5; a", "[10, 2, 15]"},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{`let h = {"x": 1}; h["y"] = 2; h["x"] -= 3; h`, `{"x": -2, "y": 2}`},
+		{"let a = [[1], [2]]; a[1][0] += 40; a", "[[1], [42]]"},
+		{"x = 1", errorMessage("assignment to undeclared identifier: x")},
+		{"let f = fn() { y = 1 }; f()", errorMessage("assignment to undeclared identifier: y")},
+		{"len = 1", errorMessage("assignment to undeclared identifier: len")},
+		{"let x = 1; x += true", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"let a = [1]; a[1] = 2", errorMessage("index out of range: 1 (len=1)")},
+		{`let a = [1]; a["k"] = 2`, errorMessage("array index must be INTEGER, got STRING")},
+		{"let h = {}; h[[1]] = 2", errorMessage("unusable as hash key: ARRAY")},
+		{`let s = "ab"; s[0] = "c"`, errorMessage("index assignment not supported: STRING")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// 浮動小数点数の計算
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000.0},
+		{"1.5 + 1.5", 3.0},
+		{"7 / 2", 3},
+		{"7 / 2.0", 3.5},
+		{"7.0 / 2", 3.5},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 3", 8.0},
+		{"let x = 7.5; x %= 2; x", 1.5},
+		{"1 + 0.5", 1.5},
+		{"2 * 0.25 - 1", -0.5},
+		{"let total = 7; let n = 2; total * 1.0 / n", 3.5},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"1 == 1.0", true},
+		{"1.5 != 1.5", false},
+		{"2 > 1.5", true},
+		{"0.5 < 0.25", false},
+		{`"avg: " + 2.5`, "avg: 2.5"},
+		{`3.0 + " items"`, "3.0 items"},
+		{"1.5 + true", errorMessage("type mismatch: FLOAT + BOOLEAN")},
+		{"-true", errorMessage("unknown operator: -BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// 数値の組み込み関数
+func TestNumberBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(3)", 3},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{`int(" 42 ")`, 42},
+		{"int(true)", 1},
+		{"float(2)", 2.0},
+		{"float(2.5)", 2.5},
+		{`float("1e-1")`, 0.1},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(2.4)", 2},
+		{"round(7)", 7},
+		{"round(3.14159, 2)", 3.14},
+		{"round(1234, -2)", 1200.0},
+		{"floor(1.7)", 1},
+		{"floor(-1.5)", -2},
+		{"ceil(1.2)", 2},
+		{"ceil(-1.5)", -1},
+		{"ceil(5)", 5},
+		{`int("3.5")`, errorMessage(`could not convert "3.5" to INTEGER`)},
+		{`float("abc")`, errorMessage(`could not convert "abc" to FLOAT`)},
+		{"int(1e300)", errorMessage("cannot convert 1e+300 to INTEGER")},
+		{"int([])", errorMessage("argument to `int` not supported, got ARRAY")},
+		{`floor("1")`, errorMessage("argument to `floor` must be INTEGER or FLOAT, got STRING")},
+		{"round(1.5, 1.5)", errorMessage("second argument to `round` must be INTEGER, got FLOAT")},
+		{"round()", errorMessage("wrong number of arguments. got=0, want=1 or 2")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// Goのpanicにならず、エラーオブジェクトになる
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 / 0", errorMessage("division by zero")},
+		{"let x = 0; 10 / x", errorMessage("division by zero")},
+		{"let x = 5; x /= 0", errorMessage("division by zero")},
+		{"5 % 0", errorMessage("modulo by zero")},
+		{"let x = 5; x %= 0", errorMessage("modulo by zero")},
+		{`"a" < 1`, errorMessage("type mismatch: STRING < INTEGER")},
+		{"true <= false", errorMessage("unknown operator: BOOLEAN <= BOOLEAN")},
+		{"false || x", errorMessage("identifier not found: x")},
+		{"let add = fn(a, b) { a + b }; add(1)", errorMessage("wrong number of arguments to add: want=2, got=1")},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", errorMessage("wrong number of arguments to add: want=2, got=3")},
+		{"fn(x) { x }()", errorMessage("wrong number of arguments to <anonymous>: want=1, got=0")},
+		{"let f = fn() { let x = 1; }; f()", nil},
+		{"let f = fn() { }; f()", nil},
+		{"let f = fn() { let x = 1; }; [f()]", "[null]"},
+		{"let x = if (true) { let y = 1; }; x", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated == nil || object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// エラーの呼び出し履歴
+func TestErrorStack(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStack []string
+	}{
+		{"1 + true", []string{}},
+		{"let f = fn() { 1 + true }; f()", []string{"f 1:28"}},
+		{
+			"let inner = fn(x) {\n  x + foo\n};\nlet apply = fn(f, v) { f(v) };\nlet outer = fn() {\n  apply(fn(y) { inner(y) }, 1)\n};\nouter();",
+			[]string{"inner 6:17", "<anonymous> 4:24", "apply 6:3", "outer 8:1"},
+		},
+		{"let f = fn(x) { x }; let g = fn() { f() }; g()", []string{"g 1:44"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		stack := []string{}
+		for _, frame := range errObj.Stack {
+			stack = append(stack, frame.Function+" "+frame.Pos.String())
+		}
+		if strings.Join(stack, ", ") != strings.Join(tt.expectedStack, ", ") {
+			t.Errorf("wrong stack for %q. expected=%v, got=%v", tt.input, tt.expectedStack, stack)
+		}
+	}
+}
+
+// 文字列は文字 (コードポイント) 単位で扱う
+func TestStringUnicode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("アクア")`, 3},
+		{`byteLen("アクア")`, 9},
+		{`byteLen("abc")`, 3},
+		{`let 名前 = "アクアマリン"; len(名前)`, 6},
+		{`"アクア"[0]`, "ア"},
+		{`"アクア"[1]`, "ク"},
+		{`"アクア"[-1]`, "ア"},
+		{`"abc"[1]`, "b"},
+		{`slice("アクアマリン", 3)`, "マリン"},
+		{`slice("アクアマリン", 1, -2)`, "クアマ"},
+		{`slice("abc", 2, 1)`, ""},
+		{`let s = ""; for (c in "日本語") { s = c + s; }; s`, "語本日"},
+		{`bytes("aあ")`, "[97, 227, 129, 130]"},
+		{`"アクア"[3]`, errorMessage("index out of range: 3 (len=3)")},
+		{`"abc"["a"]`, errorMessage("string index must be INTEGER, got STRING")},
+		{`bytes(1)`, errorMessage("argument to `bytes` must be STRING, got INTEGER")},
+		{`slice(1, 0)`, errorMessage("argument to `slice` must be ARRAY or STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// 埋め込み式を含む文字列
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let name = "Aqua"; let count = 2; "Hello ${name}, you have ${count + 1} items"`, "Hello Aqua, you have 3 items"},
+		{`"${1.5} ${true} ${if (false) { 1 }}"`, "1.5 true null"},
+		{`"${[1, "a"]} ${{"k": "v"}}"`, `[1, "a"] {"k": "v"}`},
+		{`"${"a" + "${1 + 1}"}!"`, "a2!"},
+		{`"${"x"}"`, "x"},
+		{`"${"a${1}"}"`, "a1"},
+		{`"${ "${1}" }!"`, "1!"},
+		{`"\${x}"`, "${x}"},
+		{`let f = fn(n) { "n=${n}" }; f(4)`, "n=4"},
+		{`"${x}"`, errorMessage("identifier not found: x")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, str.Value)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// 等しさの比較
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" != "b"`, true},
+		{`"1" == 1`, false},
+		{`1 != "1"`, true},
+		{"1 == 1.0", true},
+		{"true == 1", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] != [1, 2, 3]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"len == len", true},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", true},
+		{`{1: "x"}[1.0]`, "x"},
+		{`{2.5: "y"}[2.5]`, "y"},
+		{`has({1: "x"}, 1.0)`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil || object.Display(evaluated) != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+// 実行の制限
+func testEvalLimits(ctx context.Context, input string, limits object.Limits) object.Object {
+	l := lexer.New("", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return EvalContext(ctx, program, env, limits)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   object.Limits
+		expected interface{} // 整数の結果、またはエラーのメッセージ
+	}{
+		{"let i = 0; while (i < 10) { i += 1; } i", object.Limits{MaxSteps: 1000}, 10},
+		{"while (true) {}", object.Limits{MaxSteps: 1000}, "step limit exceeded: 1000"},
+		{"let f = fn(n) { f(n + 1) }; f(0)", object.Limits{}, "stack overflow"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(49)", object.Limits{MaxDepth: 50}, 49},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)", object.Limits{MaxDepth: 50}, "stack overflow"},
+		{`let s = "ab"; s = s + s; len(s)`, object.Limits{MaxStringLen: 4}, 4},
+		{`let s = "ab"; while (true) { s = s + s; }`, object.Limits{MaxStringLen: 100}, "string too long: 128 bytes (limit 100)"},
+		{`let s = "ab"; "${s}${s}${s}"`, object.Limits{MaxStringLen: 5}, "string too long: 6 bytes (limit 5)"},
+		{"[1, 2, 3, 4]", object.Limits{MaxCollectionLen: 3}, "array too large: 4 elements (limit 3)"},
+		{"let a = []; while (true) { a = push(a, 0); }", object.Limits{MaxCollectionLen: 5}, "array too large: 6 elements (limit 5)"},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1; }", object.Limits{MaxCollectionLen: 5}, "hash too large: 6 pairs (limit 5)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalLimits(context.Background(), tt.input, tt.limits)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+// 制限を指定しなくても深い再帰はstack overflowになる
+func TestDefaultMaxDepth(t *testing.T) {
+	evaluated := testEval("let f = fn(n) { f(n + 1) }; f(0)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "stack overflow" {
+		t.Fatalf("expected stack overflow. got=%T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != DefaultMaxDepth {
+		t.Errorf("wrong stack length. want=%d, got=%d", DefaultMaxDepth, len(errObj.Stack))
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluated := testEvalLimits(canceled, "1 + 1", object.Limits{})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "execution canceled: context canceled" {
+		t.Errorf("expected cancel error. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	evaluated = testEvalLimits(ctx, "let f = fn() { while (true) {} }; f()", object.Limits{})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "execution canceled: context deadline exceeded" {
+		t.Errorf("expected deadline error. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+// 前の実行で作った関数も、呼び出したときの制限で実行する
+func TestLimitsFollowCaller(t *testing.T) {
+	l := lexer.New("", "let make = fn() { fn() { while (true) {} } }; let loop = make();")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	if result := EvalContext(context.Background(), program, env, object.Limits{}); isError(result) {
+		t.Fatalf("unexpected error: %s", result.Inspect())
+	}
+
+	p = parser.New(lexer.New("", "loop()"))
+	evaluated := EvalContext(context.Background(), p.ParseProgram(), env, object.Limits{MaxSteps: 100})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "step limit exceeded: 100" {
+		t.Errorf("expected step limit error. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	loop, _ := env.Get("loop")
+	evaluated = ApplyFunctionContext(context.Background(), loop, nil, object.Limits{MaxSteps: 100}, nil)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "step limit exceeded: 100" {
+		t.Errorf("expected step limit error from ApplyFunctionContext. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+// 組み込み関数はホストの出力先・時計・権限を使う
+func TestHostBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		grants   object.Capability
+		expected string // 出力、またはエラーのメッセージ
+	}{
+		{`output("a", 1); output([1, "b"])`, object.CapIO, "a\n1\n[1, \"b\"]\n"},
+		{`output(now())`, object.CapIO | object.CapTime, "2024-02-29\n"},
+		{`output(argc())`, object.CapIO | object.CapProc, "0\n"},
+		{`output(1)`, object.CapNone, "permission denied: output requires the io capability"},
+		{`now()`, object.CapIO, "permission denied: now requires the time capability"},
+		{`readFile("x.aq")`, object.CapIO, "permission denied: readFile requires the fs capability"},
+		{`writeFile("x.aq", "")`, object.CapIO, "permission denied: writeFile requires the fs capability"},
+		{`getenv("HOME")`, object.CapIO, "permission denied: getenv requires the env capability"},
+		{`argv(0)`, object.CapIO, "permission denied: argv requires the proc capability"},
+		// 関数の中から呼んでも同じ
+		{`let f = fn() { now() }; f()`, object.CapIO, "permission denied: now requires the time capability"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		host := &object.Host{
+			Grants: tt.grants,
+			Stdout: &out,
+			Now:    func() time.Time { return time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC) },
+		}
+
+		env := object.NewEnvironment()
+		env.SetHost(host)
+		evaluated := Eval(parser.New(lexer.New("", tt.input)).ParseProgram(), env)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != tt.expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+			}
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestFileAndEnvBuiltins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.txt")
+	t.Setenv("AQUAMARINE_TEST", "blue")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{fmt.Sprintf(`writeFile(%q, getenv("AQUAMARINE_TEST") + "\n"); readFile(%q)`, path, path), "blue\n"},
+		{`let v = getenv("AQUAMARINE_UNSET"); if (v) { 1 } else { 0 }`, 0},
+		{`readFile(1)`, "argument to `readFile` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetHost(&object.Host{Grants: object.CapFS | object.CapEnv})
+		evaluated := Eval(parser.New(lexer.New("", tt.input)).ParseProgram(), env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: wrong string. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected result. got=%T(%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(&object.Host{Grants: object.CapFS})
+	evaluated := Eval(parser.New(lexer.New("", `readFile("no/such/file")`)).ParseProgram(), env)
+	if errObj, ok := evaluated.(*object.Error); !ok || !strings.HasPrefix(errObj.Message, "readFile: ") {
+		t.Errorf("expected readFile error. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+// ディレクトリにファイルを作る (ファイル名 -> 内容)
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ファイルとして評価する (import はファイルのディレクトリから探す)
+func testEvalFile(t *testing.T, path string, host *object.Host) object.Object {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.New(path, string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	env := object.NewEnvironment()
+	env.SetHost(host)
+	return Eval(program, env)
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	writeFiles(t, dir, map[string]string{
+		"util.aq": `import "counter.aq" as counter;
+let secret = 1;
+export let double = fn(x) { counter.tick(); x * 2 };
+export let twice = fn(f, x) { f(f(x)) };`,
+		"counter.aq": `let n = 0;
+export let tick = fn() { n += 1; n };
+export let count = fn() { n };`,
+		"lib/strings.aq": `export let greet = fn(name) { "hello " + name };`,
+		"main.aq": `import "util.aq" as util;
+import "./util.aq" as same;
+import "counter.aq" as counter;
+import "strings.aq" as strings;
+util.double(1) + same.twice(util.double, 1) + counter.count() * 100;`,
+		"greet.aq": `import "strings.aq" as s; s.greet("aq")`,
+	})
+
+	host := &object.Host{Modules: object.NewModules(lib)}
+	// 同じモジュールは一度だけ評価するので、counterの状態も共有される
+	// 2 + 4 + 3回のtick * 100
+	testIntegerObject(t, testEvalFile(t, filepath.Join(dir, "main.aq"), host), 306)
+
+	// 検索パスから見つける
+	evaluated := testEvalFile(t, filepath.Join(dir, "greet.aq"), &object.Host{Modules: object.NewModules(lib)})
+	if str, ok := evaluated.(*object.String); !ok || str.Value != "hello aq" {
+		t.Errorf("wrong result. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func TestModuleErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"util.aq":    `let secret = 1; export let pi = 3;`,
+		"a.aq":       `import "b.aq" as b; export let x = 1;`,
+		"b.aq":       `import "c.aq" as c;`,
+		"c.aq":       `import "a.aq" as a;`,
+		"broken.aq":  `export let x = missing;`,
+		"syntax.aq":  `let = 1;`,
+		"secret.aq":  `import "util.aq" as u; u.secret`,
+		"member.aq":  `let x = 1; x.y`,
+		"missing.aq": `import "nothing.aq" as n;`,
+		"cycle.aq":   `import "a.aq" as a;`,
+		"runtime.aq": `import "broken.aq" as b;`,
+		"parse.aq":   `import "syntax.aq" as s;`,
+		"sub/up.aq":  `import "../util.aq" as u;`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"secret.aq", filepath.Join(dir, "util.aq") + " does not export secret"},
+		{"member.aq", "INTEGER has no member y"},
+		{"missing.aq", `module not found: "nothing.aq"`},
+		{"cycle.aq", "import cycle: " + strings.Join([]string{
+			filepath.Join(dir, "a.aq"), filepath.Join(dir, "b.aq"),
+			filepath.Join(dir, "c.aq"), filepath.Join(dir, "a.aq"),
+		}, " -> ")},
+		{"runtime.aq", "identifier not found: missing"},
+		{"parse.aq", "syntax error in " + filepath.Join(dir, "syntax.aq") + ":\n" +
+			filepath.Join(dir, "syntax.aq") + ":1:5: expected next token to be INDENT, got = instead"},
+		// fsの権限が無ければ、importしたファイルのディレクトリと検索パスの外は読めない
+		{"sub/up.aq", `permission denied: importing "../util.aq" from outside the module directories requires the fs capability`},
+	}
+
+	for _, tt := range tests {
+		host := &object.Host{Modules: object.NewModules()}
+		evaluated := testEvalFile(t, filepath.Join(dir, tt.file), host)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.file, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message.\nexpected=%q\ngot=%q", tt.file, tt.expected, errObj.Message)
+		}
+	}
+
+	// モジュールの中のエラーはimportした位置も表示する
+	evaluated := testEvalFile(t, filepath.Join(dir, "runtime.aq"), &object.Host{Modules: object.NewModules()})
+	expected := "ERROR: " + filepath.Join(dir, "broken.aq") + ":1:16: identifier not found: missing\n" +
+		"    at import " + filepath.Join(dir, "broken.aq") + " (" + filepath.Join(dir, "runtime.aq") + ":1:1)"
+	if tb := evaluated.(*object.Error).Traceback(); tb != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=%q", expected, tb)
+	}
+
+	// ホストがモジュールを扱えなければimportできない
+	evaluated = testEval(`import "util.aq" as u;`)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "import is not available in this interpreter" {
+		t.Errorf("expected import error. got=%T(%+v)", evaluated, evaluated)
+	}
+}
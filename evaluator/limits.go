@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+// 関数呼び出しの深さの既定の上限 (Goのスタックが溢れる前に止める)
+const DefaultMaxDepth = 10000
+
+// 取り消しを調べる間隔 (評価するノードの数)
+const checkInterval = 128
+
+// ctxの取り消し・期限と制限を守りながら評価する
+// 制限を超えたときや取り消されたときは*object.Errorを返す
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) object.Object {
+	prev := env.Execution()
+	env.SetExecution(&object.Execution{Context: ctx, Limits: limits, Host: env.Host()})
+	defer env.SetExecution(prev)
+
+	return Eval(node, env)
+}
+
+// 関数・組み込み関数をctxと制限を守りながら、hostの権限で呼び出す
+func ApplyFunctionContext(ctx context.Context, fn object.Object, args []object.Object, limits object.Limits, host *object.Host) object.Object {
+	return applyFunction(fn, args, noPos, &object.Execution{Context: ctx, Limits: limits, Host: host})
+}
+
+// 環境の実行状態 (まだ無ければ制限の無い状態を作る)
+func execution(env *object.Environment) *object.Execution {
+	if env == nil {
+		return &object.Execution{}
+	}
+	x := env.Execution()
+	if x == nil {
+		x = &object.Execution{Host: env.Host()}
+		env.SetExecution(x)
+	}
+	return x
+}
+
+// ノードを1つ評価する前に、手数と取り消しを調べる
+func step(x *object.Execution) *object.Error {
+	x.Steps++
+	if x.Limits.MaxSteps > 0 && x.Steps > x.Limits.MaxSteps {
+		return newError("step limit exceeded: %d", x.Limits.MaxSteps)
+	}
+	if x.Context != nil && (x.Steps-1)%checkInterval == 0 {
+		if err := x.Context.Err(); err != nil {
+			return newError("execution canceled: %s", err)
+		}
+	}
+	return nil
+}
+
+// 関数呼び出しの深さを1つ増やす (戻るときはleaveを呼ぶ)
+func enter(x *object.Execution) *object.Error {
+	max := x.Limits.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if x.Depth >= max {
+		return newError("stack overflow")
+	}
+	x.Depth++
+	return nil
+}
+
+func leave(x *object.Execution) {
+	x.Depth--
+}
+
+// 作った文字列・配列・ハッシュの大きさを調べる
+func checkSize(x *object.Execution, obj object.Object) *object.Error {
+	limits := x.Limits
+
+	switch obj := obj.(type) {
+	case *object.String:
+		if limits.MaxStringLen > 0 && len(obj.Value) > limits.MaxStringLen {
+			return newError("string too long: %d bytes (limit %d)", len(obj.Value), limits.MaxStringLen)
+		}
+	case *object.Array:
+		if limits.MaxCollectionLen > 0 && len(obj.Elements) > limits.MaxCollectionLen {
+			return newError("array too large: %d elements (limit %d)", len(obj.Elements), limits.MaxCollectionLen)
+		}
+	case *object.Hash:
+		if limits.MaxCollectionLen > 0 && len(obj.Pairs) > limits.MaxCollectionLen {
+			return newError("hash too large: %d pairs (limit %d)", len(obj.Pairs), limits.MaxCollectionLen)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+)
+
+// import "path" as name
+// モジュールはインタプリタごとに一度だけ、それぞれの環境で評価する
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	x := execution(env)
+	if x.Host == nil || x.Host.Modules == nil {
+		return newError("import is not available in this interpreter")
+	}
+	modules := x.Host.Modules
+
+	path, errObj := resolveModule(node.Path.Value, node.Pos().Filename, modules.SearchPath, x.Host.Grants.Has(object.CapFS))
+	if errObj != nil {
+		return errObj
+	}
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+
+	if module, ok := modules.Lookup(key); ok {
+		return module
+	}
+	if err := modules.Begin(key, path); err != nil {
+		return newError("%s", err)
+	}
+
+	module := loadModule(path, x)
+	if errObj, ok := module.(*object.Error); ok {
+		modules.Finish(key, nil)
+		errObj.Stack = append(errObj.Stack, object.StackFrame{Function: "import " + path, Pos: node.Pos()})
+		return errObj
+	}
+	modules.Finish(key, module.(*object.Module))
+	return module
+}
+
+// モジュールのファイルを探す
+// importしたファイルのディレクトリ、検索パスのディレクトリの順に探す
+// fsの権限が無ければ、それらのディレクトリの外 (絶対パスや .. で抜けた先) は読まない
+// (fsの権限があれば絶対パスも使え、ファイルではないソース (-e や標準入力) からは今のディレクトリも探す)
+func resolveModule(path, from string, searchPath []string, fs bool) (string, *object.Error) {
+	var dirs []string
+	if info, err := os.Stat(from); err == nil && info.Mode().IsRegular() {
+		dirs = append(dirs, filepath.Dir(from))
+	} else if fs {
+		dirs = append(dirs, ".")
+	}
+	dirs = append(dirs, searchPath...)
+
+	if filepath.IsAbs(path) {
+		if !fs {
+			return "", newError("permission denied: importing an absolute path requires the fs capability")
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+		return "", newError("module not found: %q", path)
+	}
+
+	outside := false
+	for _, d := range dirs {
+		c := filepath.Join(d, path)
+		if info, err := os.Stat(c); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if !fs && !insideDirs(c, dirs) {
+			outside = true
+			continue
+		}
+		return c, nil
+	}
+
+	if outside {
+		return "", newError("permission denied: importing %q from outside the module directories requires the fs capability", path)
+	}
+	return "", newError("module not found: %q", path)
+}
+
+// fileがdirsのどれかの中にあるか (シンボリックリンクはたどった先で比べる)
+func insideDirs(file string, dirs []string) bool {
+	real, err := realPath(file)
+	if err != nil {
+		return false
+	}
+	for _, d := range dirs {
+		root, err := realPath(d)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, real)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func realPath(path string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// モジュールを構文解析して、新しい環境で評価する
+func loadModule(path string, x *object.Execution) object.Object {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return newError("cannot import %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(path, string(src)))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return newError("syntax error in %s:\n%s", path, strings.Join(diag.Strings(p.Diagnostics()), "\n"))
+	}
+
+	env := object.NewEnvironment()
+	env.SetHost(x.Host)
+	// 手数や深さは、importした側の実行と合わせて数える
+	env.SetExecution(x)
+
+	result := Eval(program, env)
+	if isError(result) {
+		return result
+	}
+
+	exports := map[string]bool{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			exports[export.Statement.Name.Value] = true
+		}
+	}
+
+	return &object.Module{Path: path, Env: env, Exports: exports}
+}
+
+// モジュールがexportした名前 util.name
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	module, ok := left.(*object.Module)
+	if !ok {
+		return newError("%s has no member %s", left.Type(), node.Name.Value)
+	}
+	value, ok := module.Get(node.Name.Value)
+	if !ok {
+		return newError("%s does not export %s", module.Path, node.Name.Value)
+	}
+	return value
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/takeru-a/golang_interpreterlang/format"
+)
+
+// aquamarine fmt [-w] [-d] [files...]
+// ファイルを指定しないときは標準入力を整形して標準出力に書く
+func runFormat(args []string) int {
+	fmtFlags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fmtFlags.Bool("w", false, "整形した結果でファイルを書き換える")
+	diff := fmtFlags.Bool("d", false, "整形前との差分を表示する")
+	fmtFlags.Parse(args)
+
+	if fmtFlags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "fmt: -w cannot be used with standard input")
+			return exitUsage
+		}
+		return formatFile("-", false, *diff)
+	}
+
+	code := exitOK
+	for _, path := range fmtFlags.Args() {
+		if c := formatFile(path, *write, *diff); c > code {
+			code = c
+		}
+	}
+	return code
+}
+
+// 1つのファイルを整形する ("-" は標準入力)
+func formatFile(path string, write, diff bool) int {
+	var src []byte
+	var err error
+	name := path
+	if path == "-" {
+		src, err = io.ReadAll(os.Stdin)
+		name = "<stdin>"
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	out, err := format.Source(name, string(src))
+	if err != nil {
+		var fe *format.Error
+		if errors.As(err, &fe) {
+			for _, d := range fe.Diagnostics {
+				fmt.Fprintln(os.Stderr, d.Format())
+			}
+			return exitError
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if diff {
+		fmt.Print(format.Diff(name+".orig", name, string(src), out))
+	}
+	if write {
+		if out == string(src) {
+			return exitOK
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		if err := os.WriteFile(path, []byte(out), info.Mode().Perm()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+	if !write && !diff {
+		fmt.Print(out)
+	}
+	return exitOK
+}
@@ -0,0 +1,403 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+const (
+	indentWidth  = 4   // 字下げの幅
+	maxLineWidth = 100 // これより長い呼び出しや配列は要素ごとに改行する
+)
+
+// 式の優先度 (識別子・リテラル・呼び出しなどは括弧が要らない)
+const atom = parser.INDEX + 1
+
+// 構文エラーのため整形できなかった
+type Error struct {
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *Error) Error() string {
+	return strings.Join(diag.Strings(e.Diagnostics), "\n")
+}
+
+// ソースを決まった書式に整形する
+// コメントは残し、整形済みのソースを整形しても変わらない
+func Source(filename, src string) (string, error) {
+	p := parser.New(lexer.New(filename, src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return "", &Error{Diagnostics: p.Diagnostics()}
+	}
+
+	pr := &printer{src: src, comments: collectComments(filename, src)}
+
+	var out strings.Builder
+	if strings.HasPrefix(src, "#!") {
+		// シバン行はそのまま残す
+		line := src
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			line = src[:i]
+		}
+		out.WriteString(strings.TrimRight(line, " \t\r") + "\n")
+	}
+	out.WriteString(pr.stmtList(program.Statements, 0, len(src)))
+
+	return out.String(), nil
+}
+
+// ソース中のすべてのコメント (出現順)
+func collectComments(filename, src string) []token.Comment {
+	var comments []token.Comment
+
+	l := lexer.New(filename, src)
+	for {
+		tok := l.NextToken()
+		comments = append(comments, tok.Leading...)
+		if tok.Type == token.EOF {
+			return comments
+		}
+	}
+}
+
+type printer struct {
+	src      string
+	comments []token.Comment
+	next     int // 次に出力するコメント
+	lastLine int // 最後に出力した要素のソース上の行
+}
+
+func indentString(indent int) string {
+	return strings.Repeat(" ", indent*indentWidth)
+}
+
+// 文の並び (endはブロックの終わりの位置で、それより前のコメントを出力する)
+func (p *printer) stmtList(stmts []ast.Statement, indent, end int) string {
+	var out strings.Builder
+	first := true
+
+	for i, s := range stmts {
+		p.ownLineComments(&out, s.Pos().Offset, indent, &first)
+		p.blankLine(&out, s.Pos().Line, first)
+
+		// 同じ行の次の文より後ろのコメントは次の文のもの
+		limit := end
+		if i+1 < len(stmts) {
+			limit = stmts[i+1].Pos().Offset
+		}
+
+		out.WriteString(indentString(indent) + p.stmt(s, indent))
+		p.lastLine = s.End().Line
+		out.WriteString(p.trailingComments(s.End(), limit))
+		out.WriteString("\n")
+		first = false
+	}
+	p.ownLineComments(&out, end, indent, &first)
+
+	return out.String()
+}
+
+// ソースで空行があったところには空行を1つだけ入れる
+func (p *printer) blankLine(out *strings.Builder, line int, first bool) {
+	if !first && line > p.lastLine+1 {
+		out.WriteString("\n")
+	}
+}
+
+// beforeより前にあるコメントをそれぞれ1行に出力する
+func (p *printer) ownLineComments(out *strings.Builder, before, indent int, first *bool) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Offset < before {
+		c := p.comments[p.next]
+		p.next++
+
+		p.blankLine(out, c.Pos.Line, *first)
+		out.WriteString(indentString(indent) + commentText(c) + "\n")
+		p.lastLine = c.End.Line
+		*first = false
+	}
+}
+
+// 文の中に残ったコメントと、文と同じ行の後ろにあるコメント
+// (limitは次の文の始まりかブロックの終わりの位置で、それより後ろのコメントは次の文や外側の文のもの)
+func (p *printer) trailingComments(end token.Position, limit int) string {
+	var out strings.Builder
+
+	for p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if c.Pos.Offset >= end.Offset && (c.Pos.Line != end.Line || c.Pos.Offset >= limit) {
+			break
+		}
+		p.next++
+
+		out.WriteString(" " + commentText(c))
+		p.lastLine = c.End.Line
+	}
+
+	return out.String()
+}
+
+// 式の前にあるコメント (式の途中に書かれたもの)
+func (p *printer) inlineComments(before, indent int) string {
+	var out strings.Builder
+
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Offset < before {
+		c := p.comments[p.next]
+		p.next++
+
+		out.WriteString(commentText(c))
+		if strings.HasPrefix(c.Text, "//") {
+			out.WriteString("\n" + indentString(indent+1))
+		} else {
+			out.WriteString(" ")
+		}
+	}
+
+	return out.String()
+}
+
+func commentText(c token.Comment) string {
+	if strings.HasPrefix(c.Text, "//") {
+		return strings.TrimRight(c.Text, " \t\r")
+	}
+	return c.Text
+}
+
+// ソース上のトークンの文字列 (リテラルの書き方をそのまま残す)
+func (p *printer) text(tok token.Token) string {
+	return p.src[tok.Pos.Offset:tok.End.Offset]
+}
+
+// 文 (字下げと改行は含まない)
+func (p *printer) stmt(s ast.Statement, indent int) string {
+	col := indent * indentWidth
+
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		head := "let " + s.Name.Value + " = "
+		return head + p.expr(s.Value, indent, advance(col, head)) + ";"
+
+	case *ast.ReturnStatement:
+		return "return " + p.expr(s.ReturnValue, indent, advance(col, "return ")) + ";"
+
+	case *ast.ExpressionStatement:
+		if _, ok := s.Expression.(*ast.IfExpression); ok {
+			return p.expr(s.Expression, indent, col)
+		}
+		return p.expr(s.Expression, indent, col) + ";"
+
+	case *ast.WhileStatement:
+		head := "while ("
+		head += p.expr(s.Condition, indent, advance(col, head)) + ") "
+		return head + p.block(s.Body, indent)
+
+	case *ast.ForStatement:
+		head := "for (" + s.Variable.Value + " in "
+		head += p.expr(s.Iterable, indent, advance(col, head)) + ") "
+		return head + p.block(s.Body, indent)
+
+	case *ast.ImportStatement:
+		return "import " + p.text(s.Path.Token) + " as " + s.Name.Value + ";"
+
+	case *ast.ExportStatement:
+		head := "export let " + s.Statement.Name.Value + " = "
+		return head + p.expr(s.Statement.Value, indent, advance(col, head)) + ";"
+
+	case *ast.BreakStatement:
+		return "break;"
+
+	case *ast.ContinueStatement:
+		return "continue;"
+	}
+
+	return s.String()
+}
+
+// { ... } のブロック
+func (p *printer) block(b *ast.BlockStatement, indent int) string {
+	body := p.stmtList(b.Statements, indent+1, b.Rbrace.Pos.Offset)
+	if body == "" {
+		return "{}"
+	}
+	return "{\n" + body + indentString(indent) + "}"
+}
+
+// sを出力した後の列
+func advance(col int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return utf8.RuneCountInString(s[i+1:])
+	}
+	return col + utf8.RuneCountInString(s)
+}
+
+// 式 (前に書かれたコメントも含む)
+// indentは式を含む行の字下げ、colは式を書き始める列
+func (p *printer) expr(e ast.Expression, indent, col int) string {
+	comments := p.inlineComments(e.Pos().Offset, indent)
+	return comments + p.exprBody(e, indent, advance(col, comments))
+}
+
+func (p *printer) exprBody(e ast.Expression, indent, col int) string {
+	switch e := e.(type) {
+	case *ast.Indetifier:
+		return e.Value
+
+	case *ast.IntegerLiteral:
+		return p.text(e.Token)
+
+	case *ast.FloatLiteral:
+		return p.text(e.Token)
+
+	case *ast.Boolean:
+		return e.Token.Literal
+
+	case *ast.StringLiteral:
+		return p.text(e.Token)
+
+	case *ast.InterpolatedString:
+		var out strings.Builder
+		for i, part := range e.Parts {
+			out.WriteString(p.text(part))
+			if i < len(e.Exprs) {
+				out.WriteString(p.expr(e.Exprs[i], indent, advance(col, out.String())))
+			}
+		}
+		return out.String()
+
+	case *ast.PrefixExpression:
+		if precedence(e.Right) < parser.PREFIX {
+			return e.Operator + "(" + p.expr(e.Right, indent, col+len(e.Operator)+1) + ")"
+		}
+		return e.Operator + p.expr(e.Right, indent, col+len(e.Operator))
+
+	case *ast.InfixExpression:
+		return p.infix(e, indent, col)
+
+	case *ast.AssignExpression:
+		head := p.expr(e.Target, indent, col) + " " + e.Operator + " "
+		return head + p.expr(e.Value, indent, advance(col, head))
+
+	case *ast.IfExpression:
+		head := "if ("
+		head += p.expr(e.Condition, indent, advance(col, head)) + ") "
+		out := head + p.block(e.Consequence, indent)
+		if e.Alternative != nil {
+			out += " else " + p.block(e.Alternative, indent)
+		}
+		return out
+
+	case *ast.FunctionLiteral:
+		params := make([]string, len(e.Parameters))
+		for i, param := range e.Parameters {
+			params[i] = param.Value
+		}
+		return "fn(" + strings.Join(params, ", ") + ") " + p.block(e.Body, indent)
+
+	case *ast.CallExpression:
+		function := p.operand(e.Function, parser.CALL, indent, col)
+		return p.list(function+"(", ")", len(e.Arguments), indent, col, func(i, indent, col int) string {
+			return p.expr(e.Arguments[i], indent, col)
+		})
+
+	case *ast.IndexExpression:
+		left := p.operand(e.Left, parser.INDEX, indent, col) + "["
+		return left + p.expr(e.Index, indent, advance(col, left)) + "]"
+
+	case *ast.MemberExpression:
+		return p.operand(e.Left, parser.INDEX, indent, col) + "." + e.Name.Value
+
+	case *ast.ArrayLiteral:
+		return p.list("[", "]", len(e.Elements), indent, col, func(i, indent, col int) string {
+			return p.expr(e.Elements[i], indent, col)
+		})
+
+	case *ast.HashLiteral:
+		return p.list("{", "}", len(e.Pairs), indent, col, func(i, indent, col int) string {
+			key := p.expr(e.Pairs[i].Key, indent, col) + ": "
+			return key + p.expr(e.Pairs[i].Value, indent, advance(col, key))
+		})
+	}
+
+	return e.String()
+}
+
+// 中置式 (優先度に応じて必要な括弧だけを付ける)
+func (p *printer) infix(e *ast.InfixExpression, indent, col int) string {
+	prec := parser.Precedence(e.Token.Type)
+	rightAssoc := e.Token.Type == token.POWER
+
+	var left string
+	if lp := precedence(e.Left); lp < prec || lp == prec && rightAssoc {
+		left = "(" + p.expr(e.Left, indent, col+1) + ")"
+	} else {
+		left = p.expr(e.Left, indent, col)
+	}
+	left += " " + e.Operator + " "
+	col = advance(col, left)
+
+	_, prefix := e.Right.(*ast.PrefixExpression)
+	if rp := precedence(e.Right); !prefix && (rp < prec || rp == prec && !rightAssoc) {
+		return left + "(" + p.expr(e.Right, indent, col+1) + ")"
+	}
+	return left + p.expr(e.Right, indent, col)
+}
+
+// 呼び出し・添字の対象 (優先度が低い式は括弧で囲む)
+func (p *printer) operand(e ast.Expression, prec, indent, col int) string {
+	if precedence(e) < prec {
+		return "(" + p.expr(e, indent, col+1) + ")"
+	}
+	return p.expr(e, indent, col)
+}
+
+// 式の優先度
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+	return atom
+}
+
+// カンマ区切りの並び (openは呼び出す関数なども含めた開き括弧までの文字列)
+// 1行に収まらないときは要素ごとに改行し、最後の要素にもカンマを付ける
+func (p *printer) list(open, close string, n, indent, col int, item func(i, indent, col int) string) string {
+	next, lastLine := p.next, p.lastLine
+
+	var flat strings.Builder
+	flat.WriteString(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			flat.WriteString(", ")
+		}
+		flat.WriteString(item(i, indent, advance(col, flat.String())))
+	}
+	flat.WriteString(close)
+
+	if n == 0 || strings.Contains(flat.String(), "\n") || advance(col, flat.String()) <= maxLineWidth {
+		return flat.String()
+	}
+
+	// 出力し直すのでコメントの位置を戻す
+	p.next, p.lastLine = next, lastLine
+
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	for i := 0; i < n; i++ {
+		itemIndent := indent + 1
+		out.WriteString(indentString(itemIndent) + item(i, itemIndent, itemIndent*indentWidth) + ",\n")
+	}
+	out.WriteString(indentString(indent) + close)
+
+	return out.String()
+}
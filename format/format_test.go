@@ -0,0 +1,191 @@
+package format
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let add = fn(a,b){a+b};add(1,2)", "let add = fn(a, b) {\n    a + b;\n};\nadd(1, 2);\n"},
+		{"if(x<1){1}else{2}", "if (x < 1) {\n    1;\n} else {\n    2;\n}\n"},
+		{"while (i<3) { i+=1; }", "while (i < 3) {\n    i += 1;\n}\n"},
+		{"for(x in [1,2,]){ if (x==1) {continue} break }", "for (x in [1, 2]) {\n    if (x == 1) {\n        continue;\n    }\n    break;\n}\n"},
+		{"let f = fn(){}", "let f = fn() {};\n"},
+		{`{"a":1,"b":[1,2]}`, "{\"a\": 1, \"b\": [1, 2]};\n"},
+		// 必要な括弧だけを残す
+		{"(1+2)*3", "(1 + 2) * 3;\n"},
+		{"((1*2))+3", "1 * 2 + 3;\n"},
+		{"1-(2-3)", "1 - (2 - 3);\n"},
+		{"(2**3)**2", "(2 ** 3) ** 2;\n"},
+		{"2**(3**2)", "2 ** 3 ** 2;\n"},
+		{"-(1+2)", "-(1 + 2);\n"},
+		{"(-x)**2", "(-x) ** 2;\n"},
+		{"(fn(x){x})(1)", "fn(x) {\n    x;\n}(1);\n"},
+		{"(a||b)&&c", "(a || b) && c;\n"},
+		// リテラルの書き方はそのまま
+		{`"a\t${x+1}b"`, "\"a\\t${x + 1}b\";\n"},
+		{"1e3 + 0.50", "1e3 + 0.50;\n"},
+		// 空行は1つにまとめる
+		{"let a = 1;\n\n\n\nlet b = 2;", "let a = 1;\n\nlet b = 2;\n"},
+		{"#!/usr/bin/env aquamarine\noutput(1)", "#!/usr/bin/env aquamarine\noutput(1);\n"},
+		// モジュール
+		{`import  "lib/util.aq"  as  util`, "import \"lib/util.aq\" as util;\n"},
+		{"export let twice=fn(x){util . double(x)}", "export let twice = fn(x) {\n    util.double(x);\n};\n"},
+		{"(util.xs)[0] + (util).n", "util.xs[0] + util.n;\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source("test.aq", tt.input)
+		if err != nil {
+			t.Fatalf("Source(%q) returned error: %s", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// 先頭のコメント
+let x = 5;   // 行末のコメント
+
+/* ブロック */
+let add = fn(a, b) {
+    // 本体のコメント
+    a + b; // 式の後ろ
+    // 最後のコメント
+};   /* 後ろ */
+let y = add(1, /* 途中 */ 2);
+let a = 1; let b = 2; // bについて
+while (x) { a; b; c /* 閉じる前 */ }
+// ファイルの終わり
+`
+	expected := `// 先頭のコメント
+let x = 5; // 行末のコメント
+
+/* ブロック */
+let add = fn(a, b) {
+    // 本体のコメント
+    a + b; // 式の後ろ
+    // 最後のコメント
+}; /* 後ろ */
+let y = add(1, /* 途中 */ 2);
+let a = 1;
+let b = 2; // bについて
+while (x) {
+    a;
+    b;
+    c; /* 閉じる前 */
+}
+// ファイルの終わり
+`
+
+	got, err := Source("test.aq", input)
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+	if got != expected {
+		t.Errorf("wrong output.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestSourceLongLines(t *testing.T) {
+	input := `let result = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree, argumentNumberFour);
+let short = f(a, b);`
+	expected := `let result = someFunction(
+    argumentNumberOne,
+    argumentNumberTwo,
+    argumentNumberThree,
+    argumentNumberFour,
+);
+let short = f(a, b);
+`
+
+	got, err := Source("test.aq", input)
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+	if got != expected {
+		t.Errorf("wrong output.\nexpected=\n%s\ngot=\n%s", expected, got)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if len(line) > maxLineWidth {
+			t.Errorf("line too long: %q", line)
+		}
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	inputs := []string{
+		"let x=1+2*3;// c\nlet f=fn(a,b){a+b};f(x,2)",
+		"let h = {\"name\": \"aquamarine\", \"values\": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10], \"nested\": {\"key\": \"value\"}};",
+		"let g = fn(x) { if (x > 0) { return x * 2; } // 正\n -x };",
+		"output(1, // 1つ目\n 2)",
+		"let s = \"${ len([1,2]) }\";\n/* a */ /* b */\n",
+		"let long = call(veryLongArgumentName1, veryLongArgumentName2, fn(x) { x + 1 }, veryLongArgumentName3);",
+	}
+
+	for _, input := range inputs {
+		first, err := Source("test.aq", input)
+		if err != nil {
+			t.Fatalf("Source(%q) returned error: %s", input, err)
+		}
+		second, err := Source("test.aq", first)
+		if err != nil {
+			t.Fatalf("formatted source does not parse: %s\n%s", err, first)
+		}
+		if first != second {
+			t.Errorf("not idempotent.\nfirst=\n%s\nsecond=\n%s", first, second)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source("test.aq", "let = 1;\nlet y = 2;")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	var fe *Error
+	if !errors.As(err, &fe) {
+		t.Fatalf("err is not *Error. got=%T", err)
+	}
+	if len(fe.Diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(fe.Diagnostics))
+	}
+	if fe.Diagnostics[0].Pos.Line != 1 {
+		t.Errorf("wrong line. got=%d", fe.Diagnostics[0].Pos.Line)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,4 +8,3 @@\n 7\n 8\n 9\n-10\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got := Diff("old", "new", tt.a, tt.b)
+		if got != tt.expected {
+			t.Errorf("Diff(%q, %q) wrong.\nexpected=%q\ngot=%q", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
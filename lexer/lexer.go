@@ -1,48 +1,118 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/takeru-a/golang_interpreterlang/diag"
 	"github.com/takeru-a/golang_interpreterlang/token"
 )
 
 // 字句解析器の構造体
 type Lexer struct {
+	filename     string //ソース名
 	input        string //入力文字列
 	position     int    //現在の位置
 	readPosition int    //次の文字
-	ch           byte   // 検査中の文字
+	ch           rune   // 検査中の文字 (UTF-8を復号したもの)
+	line         int    // 検査中の文字の行
+	column       int    // 検査中の文字の列
+	diagnostics  []diag.Diagnostic // 字句解析のエラー
+	templates    []int    // 読み込み中の埋め込み式 ${...} ごとの '{' の深さ
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+// filenameはエラーメッセージなどの位置情報に使うソース名
+func New(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	// 初期化
 	l.readChar()
+	l.skipShebang()
 	return l
 }
 
+// 字句解析のエラー ("位置: メッセージ" の形式)
+func (l *Lexer) Errors() []string {
+	return diag.Strings(l.diagnostics)
+}
+
+// 字句解析のエラー (範囲などの情報付き)
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diagnostics
+}
+
+// posから現在の位置までをエラーとして記録する
+func (l *Lexer) errorAt(pos token.Position, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Pos:      pos,
+		End:      l.pos(),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// 先頭の #! 行 (シバン) を読み飛ばす
+func (l *Lexer) skipShebang() {
+	if l.ch != '#' || l.peekChar() != '!' {
+		return
+	}
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// 検査中の文字の位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
 // 次の文字を読み込む
 func (l *Lexer) readChar() {
+	// 改行を越えたら次の行へ
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
 
 	// 終端に達したかどうかを検査
+	size := 0
 	if l.readPosition >= len(l.input) {
 		// 終端 ASCII
 		l.ch = 0
 	} else {
-		//検査する文字の指定
-		l.ch = l.input[l.readPosition]
+		//検査する文字の指定 (列は文字単位、位置はバイト単位で数える)
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
 }
 
 // 次の文字を覗き見る
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(1)
+}
 
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		// 次の文字があったら次の文字を返す
-		return l.input[l.readPosition]
+// n文字先の文字を覗き見る (peekCharはn=1)
+func (l *Lexer) peekCharAt(n int) rune {
+	offset := l.readPosition
+	for i := 1; ; i++ {
+		if offset >= len(l.input) {
+			return 0
+		}
+		ch, size := utf8.DecodeRuneInString(l.input[offset:])
+		if i == n {
+			return ch
+		}
+		offset += size
 	}
 }
 
@@ -53,43 +123,143 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// 空白とコメントを読み飛ばし、読み飛ばしたコメントを返す
+func (l *Lexer) skipWhitespaceAndComments() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return comments
+		}
+
+		pos := l.pos()
+		if l.peekChar() == '/' {
+			l.skipLineComment()
+		} else {
+			l.skipBlockComment(pos)
+		}
+		comments = append(comments, token.Comment{
+			Text: l.input[pos.Offset:l.position],
+			Pos:  pos,
+			End:  l.pos(),
+		})
+	}
+}
+
+// "//" から行末までを読み飛ばす (改行は含めない)
+func (l *Lexer) skipLineComment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// "/*" から対応する "*/" までを読み飛ばす (入れ子にできる)
+func (l *Lexer) skipBlockComment(start token.Position) {
+	depth := 0
+	for {
+		switch {
+		case l.ch == 0:
+			l.errorAt(start, "unterminated block comment")
+			return
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return
+			}
+		}
+		l.readChar()
+	}
+}
+
 // 字句解析器の実装
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// 数値を読み込む
+// 小数点か指数 (1.5, 2e10, 3.0e-2) を含む場合は浮動小数点数になる
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekCharAt(2)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 // トークンの作成
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// 文字が英字かどうかを判定
-func isLetter(ch byte) bool {
-	// ASCII Code 文字の定義
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// 次の文字が'='なら複合代入演算子のトークンを作る
+func (l *Lexer) newOperatorToken(tokenType, assignType token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: assignType, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(tokenType, l.ch)
+}
+
+// 2文字の演算子のトークンを作る (&&, ||, ** など)
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// 識別子に使える文字かどうかを判定 (日本語などUnicodeの文字も含む)
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 // 文字が数字かどうかを判定
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
 // トークンを読み込み、現在の文字に基づいてトークンを返す
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
-	l.skipWhitespace()
+	comments := l.skipWhitespaceAndComments()
+	pos := l.pos()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -102,9 +272,27 @@ func (l *Lexer) NextToken() token.Token {
 		}
 
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newOperatorToken(token.MINUS, token.MINUS_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.newTwoCharToken(token.POWER)
+		} else {
+			tok = l.newOperatorToken(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		tok = l.newOperatorToken(token.PERCENT, token.PERCENT_ASSIGN)
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -115,9 +303,13 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newOperatorToken(token.SLASH, token.SLASH_ASSIGN)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -125,18 +317,36 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newOperatorToken(token.PLUS, token.PLUS_ASSIGN)
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		// 埋め込み式の終わりなら文字列の続きを読む
+		if n := len(l.templates); n > 0 {
+			if l.templates[n-1] == 0 {
+				l.templates = l.templates[:n-1]
+				tok.Type, tok.Literal = l.readString(true)
+				break
+			}
+			l.templates[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		tok = l.newOperatorToken(token.LT, token.LT_EQ)
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		tok = l.newOperatorToken(token.GT, token.GT_EQ)
 	case '"':
+		tok.Type, tok.Literal = l.readString(false)
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
 
 	case 0:
 		tok.Literal = ""
@@ -145,30 +355,137 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End, tok.Leading = pos, l.pos(), comments
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos, tok.End, tok.Leading = pos, l.pos(), comments
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	tok.Pos, tok.End, tok.Leading = pos, l.pos(), comments
 	return tok
 }
 
 // 文字列の字句解析ヘルパー関数
-func (l *Lexer) readString() string {
+// エスケープシーケンス (\n, \t, \", \\, \u{...} など) を解釈して文字列を読み込む
+// "${" で止まったときは埋め込み式の前までをTEMPLATE_PART (続きのときはTEMPLATE_MID) として返す
+// continuedは埋め込み式の後 ('}') から続きを読み込むときにtrue
+func (l *Lexer) readString(continued bool) (token.TokenType, string) {
+	start := l.pos()
+	var out strings.Builder
+
+	end, part := token.TokenType(token.STRING), token.TokenType(token.TEMPLATE_PART)
+	if continued {
+		end, part = token.TEMPLATE_END, token.TEMPLATE_MID
+	}
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			return end, out.String()
+		case 0:
+			l.errorAt(start, "unterminated string")
+			return end, out.String()
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.templates = append(l.templates, 0)
+				return part, out.String()
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// "\"の次の文字を読み、エスケープした文字を書き込む
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.pos()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"':
+		out.WriteByte('"')
+	case '$':
+		out.WriteByte('$')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		l.readUnicodeEscape(out, pos)
+	case 0:
+		// 閉じていない文字列としてreadStringで報告する
+	default:
+		l.errorAt(pos, "unknown escape sequence: \\%c", l.ch)
+		out.WriteRune(l.ch)
+	}
+}
+
+// \u{XXXX} (16進数1〜6桁) を読み込む
+func (l *Lexer) readUnicodeEscape(out *strings.Builder, pos token.Position) {
+	if l.peekChar() != '{' {
+		l.errorAt(pos, "invalid unicode escape: expected {")
+		return
+	}
+	l.readChar()
+
+	var digits strings.Builder
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		digits.WriteRune(l.ch)
+	}
+
+	if digits.Len() == 0 || digits.Len() > 6 {
+		l.errorAt(pos, "invalid unicode escape: \\u{...} needs 1 to 6 hex digits")
+		return
+	}
+	if l.peekChar() != '}' {
+		l.errorAt(pos, "invalid unicode escape: missing }")
+		return
+	}
+	l.readChar()
+
+	code, _ := strconv.ParseUint(digits.String(), 16, 32)
+	r := rune(code)
+	if !utf8.ValidRune(r) {
+		l.errorAt(pos, "invalid unicode code point: \\u{%s}", digits.String())
+		return
+	}
+	out.WriteRune(r)
+}
+
+// `...` の生文字列を読み込む (エスケープなし、改行を含められる)
+func (l *Lexer) readRawString() string {
+	start := l.pos()
 	position := l.position + 1
+
 	for {
 		l.readChar()
 
-		// 文字列の最後かEOF
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '`':
+			return l.input[position:l.position]
+		case 0:
+			l.errorAt(start, "unterminated raw string")
+			return l.input[position:l.position]
 		}
 	}
+}
 
-	return l.input[position: l.position]
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
 }
@@ -12,7 +12,7 @@ func TestNextToken(t *testing.T) {
 				x + y;
 			  };
 			  let result = add(a, b);
-			  !-/*5;
+			  !-/ *5;
 			  5 < 10 > 5;
 			  if (5 < 10){
 				return true;
@@ -23,6 +23,8 @@ func TestNextToken(t *testing.T) {
 			  10 != 9;
 			  "test"
 			  "test aaa"
+			  [1, 2];
+			  {"foo": "bar"}
 			  `
 
 	tests := []struct {
@@ -105,10 +107,21 @@ func TestNextToken(t *testing.T) {
 		{token.SEMICOLON, ";"},
 		{token.STRING, "test"},
 		{token.STRING, "test aaa"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 
-	l := New(input)
+	l := New("", input)
 
 	for i, tt := range tests {
 		// tokenを取得
@@ -125,3 +138,448 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+// トークンの位置情報
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 10;\n  x == 5"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedPos     string
+		expectedEnd     string
+	}{
+		{token.LET, "test.aq:1:1", "test.aq:1:4"},
+		{token.INDENT, "test.aq:1:5", "test.aq:1:6"},
+		{token.ASSIGN, "test.aq:1:7", "test.aq:1:8"},
+		{token.INT, "test.aq:1:9", "test.aq:1:11"},
+		{token.SEMICOLON, "test.aq:1:11", "test.aq:1:12"},
+		{token.INDENT, "test.aq:2:3", "test.aq:2:4"},
+		{token.EQ, "test.aq:2:5", "test.aq:2:7"},
+		{token.INT, "test.aq:2:8", "test.aq:2:9"},
+		{token.EOF, "test.aq:2:9", "test.aq:2:10"},
+	}
+
+	l := New("test.aq", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("test[%d] - pos wrong. expected=%q, got=%q",
+				i, tt.expectedPos, tok.Pos.String())
+		}
+		if tok.End.String() != tt.expectedEnd {
+			t.Errorf("test[%d] - end wrong. expected=%q, got=%q",
+				i, tt.expectedEnd, tok.End.String())
+		}
+	}
+}
+
+// シバン行の読み飛ばし
+func TestShebang(t *testing.T) {
+	input := "#!/usr/bin/env aquamarine\nlet x = 1;"
+
+	l := New("test.aq", input)
+	tok := l.NextToken()
+	if tok.Type != token.LET {
+		t.Fatalf("tokentype wrong. expected=%q, got=%q", token.LET, tok.Type)
+	}
+	if tok.Pos.String() != "test.aq:2:1" {
+		t.Errorf("pos wrong. expected=%q, got=%q", "test.aq:2:1", tok.Pos.String())
+	}
+}
+
+// ループのキーワード
+func TestLoopKeywords(t *testing.T) {
+	input := "while for in break continue"
+
+	expected := []token.TokenType{
+		token.WHILE, token.FOR, token.IN, token.BREAK, token.CONTINUE, token.EOF,
+	}
+
+	l := New("", input)
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+// モジュールの予約語と . (小数点とは区別する)
+func TestModuleTokens(t *testing.T) {
+	input := `import "util.aq" as util; export let x = util.pi + 1.5;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IMPORT, "import"},
+		{token.STRING, "util.aq"},
+		{token.AS, "as"},
+		{token.INDENT, "util"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.INDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INDENT, "util"},
+		{token.DOT, "."},
+		{token.INDENT, "pi"},
+		{token.PLUS, "+"},
+		{token.FLOAT, "1.5"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+// 代入演算子
+func TestAssignOperators(t *testing.T) {
+	input := "x = 1; x += 2; x -= 3; x *= 4; x /= 5; a[0] = -1"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "x"}, {token.ASTERISK_ASSIGN, "*="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "5"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "a"}, {token.LBRACKET, "["}, {token.INT, "0"}, {token.RBRACKET, "]"},
+		{token.ASSIGN, "="}, {token.MINUS, "-"}, {token.INT, "1"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// 比較・論理・剰余・べき乗の演算子
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	input := "a <= b >= c && d || e % f ** g; x %= 2; & |"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INDENT, "a"}, {token.LT_EQ, "<="}, {token.INDENT, "b"}, {token.GT_EQ, ">="},
+		{token.INDENT, "c"}, {token.AND, "&&"}, {token.INDENT, "d"}, {token.OR, "||"},
+		{token.INDENT, "e"}, {token.PERCENT, "%"}, {token.INDENT, "f"}, {token.POWER, "**"},
+		{token.INDENT, "g"}, {token.SEMICOLON, ";"},
+		{token.INDENT, "x"}, {token.PERCENT_ASSIGN, "%="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.ILLEGAL, "&"}, {token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// 数値リテラル
+func TestNumberLiterals(t *testing.T) {
+	input := "5 3.14 1e3 2.5E-3 6e+2 7. 8e x.y"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e3"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.INT, "8"},
+		{token.INDENT, "e"},
+		{token.INDENT, "x"},
+		{token.DOT, "."},
+		{token.INDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// コメントは読み飛ばし、次のトークンにLeadingとして残す
+func TestComments(t *testing.T) {
+	input := `// first
+let x = 10 /* a /* nested */ b */ / 2; // trailing
+/* last */`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []string
+	}{
+		{token.LET, "let", []string{"// first"}},
+		{token.INDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "10", nil},
+		{token.SLASH, "/", []string{"/* a /* nested */ b */"}},
+		{token.INT, "2", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.EOF, "", []string{"// trailing", "/* last */"}},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if len(tok.Leading) != len(tt.expectedComments) {
+			t.Fatalf("test[%d] - wrong number of comments. expected=%d, got=%d", i, len(tt.expectedComments), len(tok.Leading))
+		}
+		for j, c := range tok.Leading {
+			if c.Text != tt.expectedComments[j] {
+				t.Errorf("test[%d] - comment[%d] wrong. expected=%q, got=%q", i, j, tt.expectedComments[j], c.Text)
+			}
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+// コメントの位置
+func TestCommentPosition(t *testing.T) {
+	l := New("", "1\n  // note\n2")
+	l.NextToken()
+	tok := l.NextToken()
+
+	if len(tok.Leading) != 1 {
+		t.Fatalf("wrong number of comments. got=%d", len(tok.Leading))
+	}
+	comment := tok.Leading[0]
+	if comment.Pos.String() != "2:3" || comment.End.String() != "2:10" {
+		t.Errorf("wrong comment span. got=%s-%s", comment.Pos, comment.End)
+	}
+}
+
+// 閉じていないブロックコメント
+func TestUnterminatedBlockComment(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 /* open", "1:3: unterminated block comment"},
+		{"/* a /* b */", "1:1: unterminated block comment"},
+		{"x\n  /* a */ /*", "2:11: unterminated block comment"},
+	}
+
+	for _, tt := range tests {
+		l := New("", tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 lexer error for %q. got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+// UTF-8の文字と日本語の識別子
+func TestUnicode(t *testing.T) {
+	input := "let 名前 = \"アクア\";\nlet _値2 = 名前＠"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+		expectedOffset  int
+	}{
+		{token.LET, "let", "1:1", 0},
+		{token.INDENT, "名前", "1:5", 4},
+		{token.ASSIGN, "=", "1:8", 11},
+		{token.STRING, "アクア", "1:10", 13},
+		{token.SEMICOLON, ";", "1:15", 24},
+		{token.LET, "let", "2:1", 26},
+		{token.INDENT, "_値2", "2:5", 30},
+		{token.ASSIGN, "=", "2:9", 36},
+		{token.INDENT, "名前", "2:11", 38},
+		{token.ILLEGAL, "＠", "2:13", 44},
+		{token.EOF, "", "2:14", 47},
+	}
+
+	l := New("", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.String() != tt.expectedPos || tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("test[%d] - position wrong. expected=%s (offset %d), got=%s (offset %d)",
+				i, tt.expectedPos, tt.expectedOffset, tok.Pos, tok.Pos.Offset)
+		}
+	}
+}
+
+// 文字列のエスケープと生文字列
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\tb\nc"`, "a\tb\nc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"C:\\path"`, `C:\path`},
+		{`"\u{3042}\u{1F600}\u{41}"`, "あ😀A"},
+		{`"two
+lines"`, "two\nlines"},
+		{"`raw \\n \"q\"`", `raw \n "q"`},
+		{"`line1\nline2`", "line1\nline2"},
+		{"``", ""},
+	}
+
+	for _, tt := range tests {
+		l := New("", tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("tokentype wrong for %s. got=%q", tt.input, tok.Type)
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("literal wrong for %s. expected=%q, got=%q", tt.input, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("unexpected lexer errors for %s: %v", tt.input, l.Errors())
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("expected EOF after %s. got=%q", tt.input, next.Type)
+		}
+	}
+}
+
+// 埋め込み式を含む文字列
+func TestStringInterpolation(t *testing.T) {
+	input := `"Hello ${name}, ${ {"a": 1}["a"] + "${x}" }!" "\${no} $5"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_PART, "Hello "},
+		{token.INDENT, "name"},
+		{token.TEMPLATE_MID, ", "},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.PLUS, "+"},
+		{token.TEMPLATE_PART, ""},
+		{token.INDENT, "x"},
+		{token.TEMPLATE_END, ""},
+		{token.TEMPLATE_END, "!"},
+		{token.STRING, "${no} $5"},
+		{token.EOF, ""},
+	}
+
+	l := New("", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+// 文字列の字句解析のエラー
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"abc`, "1:1: unterminated string"},
+		{`x + "abc\"`, "1:5: unterminated string"},
+		{"`abc", "1:1: unterminated raw string"},
+		{`"a\qb"`, `1:3: unknown escape sequence: \q`},
+		{`"\u{110000}"`, `1:2: invalid unicode code point: \u{110000}`},
+		{`"\u{}"`, `1:2: invalid unicode escape: \u{...} needs 1 to 6 hex digits`},
+		{`"\u{41"`, `1:2: invalid unicode escape: missing }`},
+		{`"\u41"`, `1:2: invalid unicode escape: expected {`},
+	}
+
+	for _, tt := range tests {
+		l := New("", tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected lexer errors for %s", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/lint"
+)
+
+// aquamarine lint [-json] [files...]
+// 問題が見つかったときは終了コード1を返す
+func runLint(args []string) int {
+	lintFlags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := lintFlags.Bool("json", false, "結果をJSONで出力する")
+	lintFlags.Parse(args)
+
+	paths := lintFlags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	diagnostics := []diag.Diagnostic{}
+	for _, path := range paths {
+		var src []byte
+		var err error
+		name := path
+		if path == "-" {
+			src, err = io.ReadAll(os.Stdin)
+			name = "<stdin>"
+		} else {
+			src, err = os.ReadFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+
+		diagnostics = append(diagnostics, lint.Source(name, string(src))...)
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d.Format())
+		}
+	}
+
+	if len(diagnostics) != 0 {
+		return exitError
+	}
+	return exitOK
+}
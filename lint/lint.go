@@ -0,0 +1,360 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+)
+
+// 規則の名前 (diag.DiagnosticのCode)
+const (
+	UndefinedName   = "undefined-name"
+	UnusedVariable  = "unused-variable"
+	UnusedParameter = "unused-parameter"
+	ShadowedBuiltin = "shadowed-builtin"
+	Unreachable     = "unreachable-code"
+	WrongArity      = "wrong-arity"
+)
+
+// ソースを構文解析して検査する
+// 構文エラーがあるときは構文エラーだけを返す
+func Source(filename, src string) []diag.Diagnostic {
+	p := parser.New(lexer.New(filename, src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return p.Diagnostics()
+	}
+	return Program(program)
+}
+
+// プログラムを実行せずに検査する (見つかった問題を位置の順に返す)
+//
+// 評価器と同じく、新しいスコープを作るのは関数だけで、
+// ブロックやループの中のletは外側のスコープに束縛される
+func Program(program *ast.Program) []diag.Diagnostic {
+	c := analyze(program)
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].Pos.Offset < c.diagnostics[j].Pos.Offset
+	})
+	return c.diagnostics
+}
+
+// 名前の宣言と参照を、それが指す宣言に対応付ける
+// (組み込み関数や未定義の名前は含まない)
+func Definitions(program *ast.Program) map[*ast.Indetifier]*Definition {
+	c := analyze(program)
+
+	defs := make(map[*ast.Indetifier]*Definition, len(c.defs))
+	converted := map[*symbol]*Definition{}
+	for ident, sym := range c.defs {
+		def, ok := converted[sym]
+		if !ok {
+			def = &Definition{Name: sym.decl, Kind: sym.kind, Func: sym.fn, Path: sym.path}
+			converted[sym] = def
+		}
+		defs[ident] = def
+	}
+	return defs
+}
+
+func analyze(program *ast.Program) *checker {
+	c := &checker{defs: map[*ast.Indetifier]*symbol{}}
+
+	top := newScope(nil)
+	c.stmtList(program.Statements, top)
+	c.closeScope(top)
+	c.checkCalls()
+
+	return c
+}
+
+// 宣言の種類
+type Kind int
+
+const (
+	Variable     Kind = iota // let
+	Parameter                // 関数の引数
+	LoopVariable             // for (x in ...) の変数
+	Module                   // import "..." as x のモジュール
+)
+
+// 名前の宣言
+type Definition struct {
+	Name *ast.Indetifier
+	Kind Kind
+	Func *ast.FunctionLiteral // let f = fn(...) で束縛した関数 (無ければnil)
+	Path string               // importしたモジュールのパス
+}
+
+// 名前の束縛
+type symbol struct {
+	kind       Kind
+	decl       *ast.Indetifier
+	used       bool
+	fn         *ast.FunctionLiteral // let f = fn(...) で束縛した関数
+	reassigned bool                 // = で別の値を代入しているか
+	path       string               // importしたモジュールのパス
+}
+
+// 関数1つ分のスコープ
+type scope struct {
+	outer   *scope
+	symbols map[string]*symbol
+	decls   []*symbol              // 宣言した順
+	funcs   []*ast.FunctionLiteral // スコープの終わりで検査する関数
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, symbols: map[string]*symbol{}}
+}
+
+func (s *scope) lookup(name string) (*symbol, bool) {
+	for ; s != nil; s = s.outer {
+		if sym, ok := s.symbols[name]; ok {
+			return sym, true
+		}
+	}
+	return nil, false
+}
+
+// 呼び出し (関数の束縛がすべてわかってから引数の数を検査する)
+type call struct {
+	sym  *symbol
+	expr *ast.CallExpression
+}
+
+type checker struct {
+	diagnostics []diag.Diagnostic
+	calls       []call
+	defs        map[*ast.Indetifier]*symbol // 名前が指す宣言
+}
+
+func (c *checker) report(severity diag.Severity, code string, node ast.Node, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diag.Diagnostic{
+		Severity: severity,
+		Pos:      node.Pos(),
+		End:      node.End(),
+		Message:  fmt.Sprintf(format, a...),
+		Code:     code,
+	})
+}
+
+// 名前を宣言する
+func (c *checker) declare(s *scope, ident *ast.Indetifier, kind Kind) *symbol {
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		c.report(diag.Warning, ShadowedBuiltin, ident, "%s shadows a builtin function", ident.Value)
+	}
+
+	sym := &symbol{kind: kind, decl: ident}
+	s.symbols[ident.Value] = sym
+	s.decls = append(s.decls, sym)
+	c.defs[ident] = sym
+	return sym
+}
+
+// 名前を参照する
+func (c *checker) resolve(s *scope, ident *ast.Indetifier) *symbol {
+	if sym, ok := s.lookup(ident.Value); ok {
+		c.defs[ident] = sym
+		return sym
+	}
+	if _, ok := evaluator.LookupBuiltin(ident.Value); !ok {
+		c.report(diag.Error, UndefinedName, ident, "undefined name: %s", ident.Value)
+	}
+	return nil
+}
+
+// スコープの終わり
+// 関数の本体はスコープ内の名前がすべて宣言されてから検査する
+// (関数の中からは後で宣言した名前や自分自身も参照できる)
+func (c *checker) closeScope(s *scope) {
+	for i := 0; i < len(s.funcs); i++ {
+		fn := s.funcs[i]
+		inner := newScope(s)
+		for _, param := range fn.Parameters {
+			c.declare(inner, param, Parameter)
+		}
+		c.stmtList(fn.Body.Statements, inner)
+		c.closeScope(inner)
+	}
+
+	for _, sym := range s.decls {
+		if sym.used || strings.HasPrefix(sym.decl.Value, "_") {
+			continue
+		}
+		switch sym.kind {
+		case Variable:
+			c.report(diag.Warning, UnusedVariable, sym.decl, "%s declared and not used", sym.decl.Value)
+		case Parameter:
+			c.report(diag.Warning, UnusedParameter, sym.decl, "parameter %s is not used", sym.decl.Value)
+		case Module:
+			c.report(diag.Warning, UnusedVariable, sym.decl, "module %s imported and not used", sym.decl.Value)
+		}
+	}
+}
+
+// 文の並び (return・break・continueの後の文は実行されない)
+func (c *checker) stmtList(stmts []ast.Statement, s *scope) {
+	for i, stmt := range stmts {
+		c.stmt(stmt, s)
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			if i+1 < len(stmts) {
+				rest := stmts[i+1:]
+				c.diagnostics = append(c.diagnostics, diag.Diagnostic{
+					Severity: diag.Warning,
+					Pos:      rest[0].Pos(),
+					End:      rest[len(rest)-1].End(),
+					Message:  "unreachable code",
+					Code:     Unreachable,
+				})
+				for _, stmt := range rest {
+					c.stmt(stmt, s)
+				}
+				return
+			}
+		}
+	}
+}
+
+func (c *checker) stmt(stmt ast.Statement, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// 値を先に検査する (let x = x + 1 の右辺は前のx)
+		c.expr(stmt.Value, s)
+		sym := c.declare(s, stmt.Name, Variable)
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			sym.fn = fn
+		}
+
+	case *ast.ImportStatement:
+		sym := c.declare(s, stmt.Name, Module)
+		sym.path = stmt.Path.Value
+
+	case *ast.ExportStatement:
+		// exportした名前はモジュールの外で使う
+		c.stmt(stmt.Statement, s)
+		if sym, ok := s.symbols[stmt.Statement.Name.Value]; ok {
+			sym.used = true
+		}
+
+	case *ast.ReturnStatement:
+		c.expr(stmt.ReturnValue, s)
+
+	case *ast.ExpressionStatement:
+		c.expr(stmt.Expression, s)
+
+	case *ast.WhileStatement:
+		c.expr(stmt.Condition, s)
+		c.stmtList(stmt.Body.Statements, s)
+
+	case *ast.ForStatement:
+		c.expr(stmt.Iterable, s)
+		c.declare(s, stmt.Variable, LoopVariable)
+		c.stmtList(stmt.Body.Statements, s)
+	}
+}
+
+func (c *checker) expr(e ast.Expression, s *scope) {
+	switch e := e.(type) {
+	case *ast.Indetifier:
+		if sym := c.resolve(s, e); sym != nil {
+			sym.used = true
+		}
+
+	case *ast.InterpolatedString:
+		for _, x := range e.Exprs {
+			c.expr(x, s)
+		}
+
+	case *ast.PrefixExpression:
+		c.expr(e.Right, s)
+
+	case *ast.InfixExpression:
+		c.expr(e.Left, s)
+		c.expr(e.Right, s)
+
+	case *ast.AssignExpression:
+		c.expr(e.Value, s)
+		if ident, ok := e.Target.(*ast.Indetifier); ok {
+			// = だけなら値を読まないので、使ったことにはしない
+			if sym := c.resolve(s, ident); sym != nil {
+				if e.Operator == "=" {
+					sym.reassigned = true
+				} else {
+					sym.used = true
+				}
+			}
+		} else {
+			c.expr(e.Target, s)
+		}
+
+	case *ast.IfExpression:
+		c.expr(e.Condition, s)
+		c.stmtList(e.Consequence.Statements, s)
+		if e.Alternative != nil {
+			c.stmtList(e.Alternative.Statements, s)
+		}
+
+	case *ast.FunctionLiteral:
+		s.funcs = append(s.funcs, e)
+
+	case *ast.CallExpression:
+		c.expr(e.Function, s)
+		for _, arg := range e.Arguments {
+			c.expr(arg, s)
+		}
+
+		switch fn := e.Function.(type) {
+		case *ast.Indetifier:
+			if sym, ok := s.lookup(fn.Value); ok {
+				c.calls = append(c.calls, call{sym: sym, expr: e})
+			}
+		case *ast.FunctionLiteral:
+			c.checkArity(e, fn, "function")
+		}
+
+	case *ast.IndexExpression:
+		c.expr(e.Left, s)
+		c.expr(e.Index, s)
+
+	case *ast.MemberExpression:
+		// モジュールの中の名前は検査しない
+		c.expr(e.Left, s)
+
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			c.expr(el, s)
+		}
+
+	case *ast.HashLiteral:
+		for _, pair := range e.Pairs {
+			c.expr(pair.Key, s)
+			c.expr(pair.Value, s)
+		}
+	}
+}
+
+// 束縛したまま代入し直していない関数の呼び出しの引数の数を検査する
+func (c *checker) checkCalls() {
+	for _, cl := range c.calls {
+		if cl.sym.fn != nil && !cl.sym.reassigned {
+			c.checkArity(cl.expr, cl.sym.fn, cl.sym.decl.Value)
+		}
+	}
+}
+
+func (c *checker) checkArity(e *ast.CallExpression, fn *ast.FunctionLiteral, name string) {
+	if len(e.Arguments) != len(fn.Parameters) {
+		c.report(diag.Error, WrongArity, e, "wrong number of arguments to %s: want=%d, got=%d",
+			name, len(fn.Parameters), len(e.Arguments))
+	}
+}
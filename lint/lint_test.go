@@ -0,0 +1,147 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // "行:列 規則名 メッセージ"
+	}{
+		// 未定義の名前
+		{"output(totl);", []string{"1:8 undefined-name undefined name: totl"}},
+		{"x = 1;", []string{"1:1 undefined-name undefined name: x"}},
+		{"output(y); let y = 1;", []string{"1:8 undefined-name undefined name: y", "1:16 unused-variable y declared and not used"}},
+		{"let f = fn() { inner }; let g = fn() { let inner = 1; inner }; f(); g();", []string{"1:16 undefined-name undefined name: inner"}},
+		// 使われていないlet・引数
+		{"let x = 1;", []string{"1:5 unused-variable x declared and not used"}},
+		{"let x = 1; x = 2;", []string{"1:5 unused-variable x declared and not used"}},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15 unused-parameter parameter b is not used"}},
+		{"let _x = 1; let f = fn(_a) { 0 }; f(1);", nil},
+		// 組み込み関数の上書き
+		{"let len = 1; output(len);", []string{"1:5 shadowed-builtin len shadows a builtin function"}},
+		{"let f = fn(first) { first }; f(1);", []string{"1:12 shadowed-builtin first shadows a builtin function"}},
+		// 到達できないコード
+		{"let f = fn() { return 1; output(2); output(3); }; f();", []string{"1:26 unreachable-code unreachable code"}},
+		{"while (true) { break; output(1); }", []string{"1:23 unreachable-code unreachable code"}},
+		// 引数の数
+		{"let add = fn(a, b) { a + b }; add(1);", []string{"1:31 wrong-arity wrong number of arguments to add: want=2, got=1"}},
+		{"fn(x) { x }(1, 2);", []string{"1:1 wrong-arity wrong number of arguments to function: want=1, got=2"}},
+		{"let add = fn(a, b) { a + b }; add = fn(a) { a }; add(1);", nil},
+		// モジュール
+		{`import "util.aq" as util;`, []string{"1:21 unused-variable module util imported and not used"}},
+		{`import "util.aq" as util; output(util.double(utl.x));`, []string{"1:46 undefined-name undefined name: utl"}},
+		{`let helper = 1; export let api = fn(x) { x + helper };`, nil},
+		// 問題のないプログラム
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; output(fib(10));", nil},
+		{"let count = 0; let inc = fn() { count += 1; limit }; let limit = 3; inc();", nil},
+		{"let xs = [1, 2]; for (x in xs) { output(\"${x}\") }", nil},
+		{"let i = 0; while (i < 3) { let sq = i * i; output(sq); i += 1; }", nil},
+		{"let h = {\"a\": 1}; h[\"b\"] = 2; output(h);", nil},
+		{"let make = fn(n) { fn(m) { n + m } }; output(make(1)(2));", nil},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Source("test.aq", tt.input)
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of diagnostics. want=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(diagnostics), diag.Strings(diagnostics))
+			continue
+		}
+		for i, d := range diagnostics {
+			got := d.Pos.String()[len("test.aq:"):] + " " + d.Code + " " + d.Message
+			if got != tt.expected[i] {
+				t.Errorf("input %q: wrong diagnostic. want=%q, got=%q", tt.input, tt.expected[i], got)
+			}
+		}
+	}
+}
+
+func TestLintSeverity(t *testing.T) {
+	diagnostics := Source("test.aq", "let x = 1; output(y);")
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. got=%v", diag.Strings(diagnostics))
+	}
+
+	if diagnostics[0].Severity != diag.Warning || diagnostics[0].Code != UnusedVariable {
+		t.Errorf("wrong first diagnostic. got=%s %s", diagnostics[0].Severity, diagnostics[0].Code)
+	}
+	if diagnostics[1].Severity != diag.Error || diagnostics[1].Code != UndefinedName {
+		t.Errorf("wrong second diagnostic. got=%s %s", diagnostics[1].Severity, diagnostics[1].Code)
+	}
+	if diagnostics[1].End.Column != 20 {
+		t.Errorf("wrong end column. want=20, got=%d", diagnostics[1].End.Column)
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	diagnostics := Source("test.aq", "let = 1; output(y);")
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%v", diag.Strings(diagnostics))
+	}
+	if diagnostics[0].Code != "" {
+		t.Errorf("syntax error has lint code %q", diagnostics[0].Code)
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+let x = add(1, 2);
+for (i in [x]) { output(i) }`
+
+	p := parser.New(lexer.New("test.aq", input))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		t.Fatalf("parser has errors: %v", diag.Strings(p.Diagnostics()))
+	}
+	defs := Definitions(program)
+
+	// 識別子の位置 (行:列) -> 宣言の位置と種類
+	tests := []struct {
+		ref      string
+		decl     string
+		kind     Kind
+		function bool
+	}{
+		{"1:5", "1:5", Variable, true},
+		{"1:22", "1:14", Parameter, false},
+		{"1:26", "1:17", Parameter, false},
+		{"2:9", "1:5", Variable, true},
+		{"3:12", "2:5", Variable, false},
+		{"3:25", "3:6", LoopVariable, false},
+	}
+
+	found := map[string]*Definition{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Indetifier); ok {
+			if def, ok := defs[ident]; ok {
+				found[fmt.Sprintf("%d:%d", ident.Pos().Line, ident.Pos().Column)] = def
+			}
+		}
+		return true
+	})
+
+	for _, tt := range tests {
+		def, ok := found[tt.ref]
+		if !ok {
+			t.Errorf("no definition for %s", tt.ref)
+			continue
+		}
+		decl := fmt.Sprintf("%d:%d", def.Name.Pos().Line, def.Name.Pos().Column)
+		if decl != tt.decl || def.Kind != tt.kind || (def.Func != nil) != tt.function {
+			t.Errorf("wrong definition for %s. got decl=%s kind=%d func=%v", tt.ref, decl, def.Kind, def.Func != nil)
+		}
+	}
+	// outputは組み込み関数なので含まない
+	if _, ok := found["3:18"]; ok {
+		t.Errorf("builtin has a definition")
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/takeru-a/golang_interpreterlang/lsp"
+)
+
+// aquamarine lsp
+// 標準入出力でLanguage Server Protocolを話す
+func runLSP() int {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
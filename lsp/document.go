@@ -0,0 +1,322 @@
+package lsp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/lint"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+// 開いている文書と、その解析結果
+type document struct {
+	uri         string
+	version     int
+	text        string
+	lineStarts  []int // 各行の先頭のバイトオフセット
+	program     *ast.Program
+	diagnostics []diag.Diagnostic
+	defs        map[*ast.Indetifier]*lint.Definition
+}
+
+// 文書を解析する
+// 構文エラーがあっても、解析できた文だけで補完や定義への移動ができる
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text}
+
+	d.lineStarts = []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(uri, text))
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	if len(d.diagnostics) == 0 {
+		d.diagnostics = lint.Program(d.program)
+	}
+	d.defs = lint.Definitions(d.program)
+
+	return d
+}
+
+// バイトオフセットをLSPの位置にする
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// LSPの位置をバイトオフセットにする (行末より後ろは行末にする)
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if utf16.IsSurrogate(r) || r < 0x10000 {
+		return 1
+	}
+	return 2
+}
+
+func (d *document) span(pos, end token.Position) Range {
+	if !end.IsValid() || end.Offset < pos.Offset {
+		end = pos
+	}
+	return Range{Start: d.position(pos.Offset), End: d.position(end.Offset)}
+}
+
+func (d *document) nodeRange(node ast.Node) Range {
+	return d.span(node.Pos(), node.End())
+}
+
+// 診断をLSPの形にする
+func (d *document) lspDiagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, dg := range d.diagnostics {
+		message := dg.Message
+		if dg.Hint != "" {
+			message += " (hint: " + dg.Hint + ")"
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.span(dg.Pos, dg.End),
+			Severity: int(dg.Severity) + 1,
+			Code:     dg.Code,
+			Source:   "aquamarine",
+			Message:  message,
+		})
+	}
+	return diagnostics
+}
+
+func contains(node ast.Node, offset int) bool {
+	return node.Pos().Offset <= offset && offset <= node.End().Offset
+}
+
+// 位置にある識別子 (識別子の直後にカーソルがあるときも含む)
+func (d *document) identAt(offset int) *ast.Indetifier {
+	var found *ast.Indetifier
+
+	ast.Inspect(d.program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.Program); !ok && !contains(n, offset) {
+			return false
+		}
+		if ident, ok := n.(*ast.Indetifier); ok {
+			found = ident
+		}
+		return true
+	})
+
+	return found
+}
+
+// 定義の位置
+func (d *document) definition(offset int) *Location {
+	ident := d.identAt(offset)
+	if ident == nil {
+		return nil
+	}
+	def, ok := d.defs[ident]
+	if !ok {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.nodeRange(def.Name)}
+}
+
+// 識別子の説明 (関数ならシグネチャ)
+func (d *document) hover(offset int) *Hover {
+	ident := d.identAt(offset)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if def, ok := d.defs[ident]; ok {
+		text = describe(def)
+	} else if _, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		text = "builtin " + ident.Value
+	} else {
+		return nil
+	}
+
+	r := d.nodeRange(ident)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```aquamarine\n" + text + "\n```"},
+		Range:    &r,
+	}
+}
+
+func describe(def *lint.Definition) string {
+	switch {
+	case def.Func != nil:
+		return signature(def.Name.Value, def.Func)
+	case def.Kind == lint.Parameter:
+		return "parameter " + def.Name.Value
+	case def.Kind == lint.LoopVariable:
+		return "for " + def.Name.Value
+	case def.Kind == lint.Module:
+		return "import " + strconv.Quote(def.Path) + " as " + def.Name.Value
+	default:
+		return "let " + def.Name.Value
+	}
+}
+
+// fn add(a, b)
+func signature(name string, fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+	}
+	return "fn " + name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// 補完候補 (予約語・組み込み関数・その位置から見える名前)
+func (d *document) completion(offset int) []CompletionItem {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	// スコープごとの名前 (内側のスコープが先)
+	var scopes [][]CompletionItem
+	current := []CompletionItem{}
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			// 位置を含まない関数の中の名前は見えない
+			if n.Body == nil || !contains(n.Body, offset) {
+				return false
+			}
+			outer := current
+			current = []CompletionItem{}
+			for _, p := range n.Parameters {
+				current = append(current, CompletionItem{Label: p.Value, Kind: CompletionItemVariable, Detail: "parameter " + p.Value})
+			}
+			ast.Inspect(n.Body, visit)
+			scopes = append(scopes, current)
+			current = outer
+			return false
+
+		case *ast.LetStatement:
+			if def, ok := d.defs[n.Name]; ok && def.Func != nil {
+				current = append(current, CompletionItem{Label: n.Name.Value, Kind: CompletionItemFunction, Detail: signature(n.Name.Value, def.Func)})
+			} else {
+				current = append(current, CompletionItem{Label: n.Name.Value, Kind: CompletionItemVariable, Detail: "let " + n.Name.Value})
+			}
+
+		case *ast.ForStatement:
+			current = append(current, CompletionItem{Label: n.Variable.Value, Kind: CompletionItemVariable, Detail: "for " + n.Variable.Value})
+
+		case *ast.ImportStatement:
+			current = append(current, CompletionItem{Label: n.Name.Value, Kind: CompletionItemModule, Detail: "import " + strconv.Quote(n.Path.Value)})
+		}
+		return true
+	}
+	ast.Inspect(d.program, visit)
+
+	scopes = append(scopes, current)
+	for _, scope := range scopes {
+		for _, item := range scope {
+			add(item)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(CompletionItem{Label: name, Kind: CompletionItemFunction, Detail: "builtin " + name})
+	}
+	for _, kw := range token.Keywords() {
+		add(CompletionItem{Label: kw, Kind: CompletionItemKeyword})
+	}
+
+	return items
+}
+
+// 文書のシンボル (letで束縛した名前、関数の中のletは子にする)
+func (d *document) symbols() []DocumentSymbol {
+	return d.stmtSymbols(d.program.Statements)
+}
+
+func (d *document) stmtSymbols(stmts []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			sym := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          d.nodeRange(stmt),
+				SelectionRange: d.nodeRange(stmt.Name),
+			}
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				sym.Kind = SymbolFunction
+				sym.Detail = signature(stmt.Name.Value, fn)
+				sym.Children = d.stmtSymbols(fn.Body.Statements)
+			}
+			symbols = append(symbols, sym)
+
+		case *ast.ImportStatement:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolModule,
+				Detail:         strconv.Quote(stmt.Path.Value),
+				Range:          d.nodeRange(stmt),
+				SelectionRange: d.nodeRange(stmt.Name),
+			})
+		case *ast.ExportStatement:
+			symbols = append(symbols, d.stmtSymbols([]ast.Statement{stmt.Statement})...)
+
+		// ブロックの中のletも同じスコープの名前
+		case *ast.WhileStatement:
+			symbols = append(symbols, d.stmtSymbols(stmt.Body.Statements)...)
+		case *ast.ForStatement:
+			symbols = append(symbols, d.stmtSymbols(stmt.Body.Statements)...)
+		case *ast.ExpressionStatement:
+			if ifExpr, ok := stmt.Expression.(*ast.IfExpression); ok {
+				symbols = append(symbols, d.stmtSymbols(ifExpr.Consequence.Statements)...)
+				if ifExpr.Alternative != nil {
+					symbols = append(symbols, d.stmtSymbols(ifExpr.Alternative.Statements)...)
+				}
+			}
+		}
+	}
+
+	return symbols
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// 標準入出力などでLSPを話すサーバー
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool  // shutdownを受け取った
+	writeErr    error // 通知を書けなかったときのエラー
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*document{},
+	}
+}
+
+// exitを受け取るか入力が終わるまでメッセージを処理する
+// shutdownを受け取らずに終わったときはエラーを返す
+func (s *Server) Run() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			break
+		}
+
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+
+	if !s.shutdown {
+		return fmt.Errorf("lsp: exited without shutdown")
+	}
+	return nil
+}
+
+// 要求・通知を1つ処理する (返すエラーは出力の失敗だけ)
+func (s *Server) handle(req *request) error {
+	result, rerr := s.dispatch(req)
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+
+	// 通知には応答しない
+	if req.ID == nil {
+		return nil
+	}
+	if rerr != nil {
+		return s.replyError(req.ID, rerr.Code, rerr.Message)
+	}
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) dispatch(req *request) (result interface{}, rerr *responseError) {
+	// 解析中の不具合でサーバーが止まらないようにする
+	defer func() {
+		if r := recover(); r != nil {
+			result, rerr = nil, &responseError{Code: codeInternalError, Message: fmt.Sprint(r)}
+		}
+	}()
+
+	switch {
+	case req.Method == "initialize":
+		s.initialized = true
+		return s.initialize(), nil
+	case !s.initialized:
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		item := params.TextDocument
+		s.update(newDocument(item.URI, item.Version, item.Text))
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// 文書全体を送る方式なので最後の変更だけを使う
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.update(newDocument(params.TextDocument.URI, params.TextDocument.Version, text))
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		// 閉じた文書の診断を消す
+		s.publish(PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+
+	case "textDocument/completion":
+		doc, offset, rerr := s.position(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.completion(offset), nil
+
+	case "textDocument/definition":
+		doc, offset, rerr := s.position(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if loc := doc.definition(offset); loc != nil {
+			return loc, nil
+		}
+		return nil, nil
+
+	case "textDocument/hover":
+		doc, offset, rerr := s.position(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if hover := doc.hover(offset); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, rerr := s.document(params.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.symbols(), nil
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func (s *Server) initialize() InitializeResult {
+	var result InitializeResult
+	result.Capabilities.TextDocumentSync = TextDocumentSyncOptions{OpenClose: true, Change: 1}
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.HoverProvider = true
+	result.Capabilities.DocumentSymbolProvider = true
+	result.ServerInfo.Name = "aquamarine"
+	return result
+}
+
+// 文書を登録し直して診断を送る
+func (s *Server) update(doc *document) {
+	s.docs[doc.uri] = doc
+	s.publish(PublishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: doc.lspDiagnostics()})
+}
+
+func (s *Server) publish(params PublishDiagnosticsParams) {
+	err := writeMessage(s.out, notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+	if err != nil && s.writeErr == nil {
+		s.writeErr = err
+	}
+}
+
+func (s *Server) document(uri string) (*document, *responseError) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+// 位置を指定する要求の文書とバイトオフセット
+func (s *Server) position(raw json.RawMessage) (*document, int, *responseError) {
+	var params TextDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, 0, invalidParams(err)
+	}
+	doc, rerr := s.document(params.TextDocument.URI)
+	if rerr != nil {
+		return nil, 0, rerr
+	}
+	return doc, doc.offset(params.Position), nil
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) error {
+	return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
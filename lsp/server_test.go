@@ -0,0 +1,440 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.aq"
+
+const testSource = `let add = fn(a, b) {
+    a + b;
+};
+let total = add(1, 2);
+let scale = fn(x) {
+    let factor = 10;
+    x * factor
+};
+output(scale(total));
+`
+
+// 台本どおりにメッセージを送るクライアント
+type scriptedClient struct {
+	in     bytes.Buffer
+	nextID int
+}
+
+func (c *scriptedClient) request(method string, params interface{}) int {
+	c.nextID++
+	c.write(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	return c.nextID
+}
+
+func (c *scriptedClient) notify(method string, params interface{}) {
+	c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *scriptedClient) write(msg interface{}) {
+	if err := writeMessage(&c.in, msg); err != nil {
+		panic(err)
+	}
+}
+
+// サーバーから受け取ったメッセージ
+type received struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// 台本を最後まで実行し、サーバーの出力を返す
+func (c *scriptedClient) run(t *testing.T) []received {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := NewServer(&c.in, &out).Run(); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	var msgs []received
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			return msgs
+		}
+		if err != nil {
+			t.Fatalf("reading server output: %s", err)
+		}
+		var msg received
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("invalid JSON from server: %s", err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func startSession(c *scriptedClient, text string) {
+	c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI, "languageId": "aquamarine", "version": 1, "text": text},
+	})
+}
+
+func endSession(c *scriptedClient) {
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+}
+
+func positionParams(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+func findResponse(t *testing.T, msgs []received, id int) received {
+	t.Helper()
+	for _, msg := range msgs {
+		if msg.ID != nil && *msg.ID == id && msg.Method == "" {
+			return msg
+		}
+	}
+	t.Fatalf("no response for request %d", id)
+	return received{}
+}
+
+func findDiagnostics(t *testing.T, msgs []received) []PublishDiagnosticsParams {
+	t.Helper()
+	var all []PublishDiagnosticsParams
+	for _, msg := range msgs {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("invalid publishDiagnostics params: %s", err)
+		}
+		all = append(all, params)
+	}
+	return all
+}
+
+func TestInitialize(t *testing.T) {
+	c := &scriptedClient{}
+	id := c.request("initialize", map[string]interface{}{})
+	endSession(c)
+	msgs := c.run(t)
+
+	var result InitializeResult
+	if err := json.Unmarshal(findResponse(t, msgs, id).Result, &result); err != nil {
+		t.Fatalf("invalid initialize result: %s", err)
+	}
+	caps := result.Capabilities
+	if caps.TextDocumentSync.Change != 1 || !caps.TextDocumentSync.OpenClose {
+		t.Errorf("wrong textDocumentSync. got=%+v", caps.TextDocumentSync)
+	}
+	if !caps.DefinitionProvider || !caps.HoverProvider || !caps.DocumentSymbolProvider {
+		t.Errorf("missing capabilities. got=%+v", caps)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, "let x = 1;\nlet = 2;\n")
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": testURI, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": "let x = 1;\noutput(x);\n"}},
+	})
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": testURI}})
+	endSession(c)
+
+	published := findDiagnostics(t, c.run(t))
+	if len(published) != 3 {
+		t.Fatalf("wrong number of publishDiagnostics. want=3, got=%d", len(published))
+	}
+
+	opened := published[0]
+	if opened.Version != 1 || len(opened.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics after open. got=%+v", opened)
+	}
+	d := opened.Diagnostics[0]
+	if d.Severity != SeverityError {
+		t.Errorf("wrong severity. got=%d", d.Severity)
+	}
+	expected := Range{Start: Position{Line: 1, Character: 4}, End: Position{Line: 1, Character: 5}}
+	if d.Range != expected {
+		t.Errorf("wrong range. want=%+v, got=%+v", expected, d.Range)
+	}
+	if !strings.Contains(d.Message, "INDENT") {
+		t.Errorf("wrong message. got=%q", d.Message)
+	}
+
+	if changed := published[1]; changed.Version != 2 || len(changed.Diagnostics) != 0 {
+		t.Errorf("diagnostics not cleared after fix. got=%+v", changed)
+	}
+	if closed := published[2]; len(closed.Diagnostics) != 0 {
+		t.Errorf("diagnostics not cleared after close. got=%+v", closed)
+	}
+}
+
+func TestLintDiagnostics(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, "let unused = 1;\noutput(totl);\n")
+	endSession(c)
+
+	published := findDiagnostics(t, c.run(t))
+	if len(published) != 1 || len(published[0].Diagnostics) != 2 {
+		t.Fatalf("wrong diagnostics. got=%+v", published)
+	}
+	diagnostics := published[0].Diagnostics
+	if diagnostics[0].Severity != SeverityWarning || diagnostics[0].Code != "unused-variable" {
+		t.Errorf("wrong first diagnostic. got=%+v", diagnostics[0])
+	}
+	if diagnostics[1].Severity != SeverityError || diagnostics[1].Code != "undefined-name" {
+		t.Errorf("wrong second diagnostic. got=%+v", diagnostics[1])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, testSource)
+	inside := c.request("textDocument/completion", positionParams(6, 4))  // scaleの本体の中
+	outside := c.request("textDocument/completion", positionParams(8, 0)) // 最上位
+	endSession(c)
+	msgs := c.run(t)
+
+	labels := func(id int) map[string]CompletionItem {
+		var items []CompletionItem
+		if err := json.Unmarshal(findResponse(t, msgs, id).Result, &items); err != nil {
+			t.Fatalf("invalid completion result: %s", err)
+		}
+		m := map[string]CompletionItem{}
+		for _, item := range items {
+			m[item.Label] = item
+		}
+		return m
+	}
+
+	in := labels(inside)
+	for _, name := range []string{"x", "factor", "add", "total", "scale", "len", "output", "let", "while"} {
+		if _, ok := in[name]; !ok {
+			t.Errorf("completion inside function missing %q", name)
+		}
+	}
+	if in["add"].Kind != CompletionItemFunction || in["add"].Detail != "fn add(a, b)" {
+		t.Errorf("wrong item for add. got=%+v", in["add"])
+	}
+	if in["let"].Kind != CompletionItemKeyword {
+		t.Errorf("wrong item for let. got=%+v", in["let"])
+	}
+
+	out := labels(outside)
+	for _, name := range []string{"x", "factor", "a", "b"} {
+		if _, ok := out[name]; ok {
+			t.Errorf("completion at top level has out-of-scope %q", name)
+		}
+	}
+	if _, ok := out["total"]; !ok {
+		t.Errorf("completion at top level missing %q", "total")
+	}
+}
+
+func TestDefinitionAndHover(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, testSource)
+	defAdd := c.request("textDocument/definition", positionParams(3, 13))    // add(1, 2) の add
+	defFactor := c.request("textDocument/definition", positionParams(6, 9))  // x * factor の factor
+	defBuiltin := c.request("textDocument/definition", positionParams(8, 1)) // output
+	hoverAdd := c.request("textDocument/hover", positionParams(3, 15))       // add の直後
+	hoverParam := c.request("textDocument/hover", positionParams(6, 4))      // x
+	hoverBuiltin := c.request("textDocument/hover", positionParams(8, 2))    // output
+	hoverNone := c.request("textDocument/hover", positionParams(3, 18))      // 数値
+	endSession(c)
+	msgs := c.run(t)
+
+	definitionTests := []struct {
+		id       int
+		expected *Range
+	}{
+		{defAdd, &Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 7}}},
+		{defFactor, &Range{Start: Position{Line: 5, Character: 8}, End: Position{Line: 5, Character: 14}}},
+		{defBuiltin, nil},
+	}
+	for _, tt := range definitionTests {
+		var loc *Location
+		if err := json.Unmarshal(findResponse(t, msgs, tt.id).Result, &loc); err != nil {
+			t.Fatalf("invalid definition result: %s", err)
+		}
+		if tt.expected == nil {
+			if loc != nil {
+				t.Errorf("expected no definition. got=%+v", loc)
+			}
+			continue
+		}
+		if loc == nil || loc.URI != testURI || loc.Range != *tt.expected {
+			t.Errorf("wrong definition. want=%+v, got=%+v", tt.expected, loc)
+		}
+	}
+
+	hoverTests := []struct {
+		id       int
+		expected string
+	}{
+		{hoverAdd, "fn add(a, b)"},
+		{hoverParam, "parameter x"},
+		{hoverBuiltin, "builtin output"},
+		{hoverNone, ""},
+	}
+	for _, tt := range hoverTests {
+		var hover *Hover
+		if err := json.Unmarshal(findResponse(t, msgs, tt.id).Result, &hover); err != nil {
+			t.Fatalf("invalid hover result: %s", err)
+		}
+		if tt.expected == "" {
+			if hover != nil {
+				t.Errorf("expected no hover. got=%+v", hover)
+			}
+			continue
+		}
+		if hover == nil || !strings.Contains(hover.Contents.Value, tt.expected) {
+			t.Errorf("wrong hover. want=%q, got=%+v", tt.expected, hover)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, testSource)
+	id := c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": testURI}})
+	endSession(c)
+
+	var symbols []DocumentSymbol
+	if err := json.Unmarshal(findResponse(t, c.run(t), id).Result, &symbols); err != nil {
+		t.Fatalf("invalid documentSymbol result: %s", err)
+	}
+
+	expected := []struct {
+		name     string
+		kind     int
+		children []string
+	}{
+		{"add", SymbolFunction, nil},
+		{"total", SymbolVariable, nil},
+		{"scale", SymbolFunction, []string{"factor"}},
+	}
+	if len(symbols) != len(expected) {
+		t.Fatalf("wrong number of symbols. want=%d, got=%d", len(expected), len(symbols))
+	}
+	for i, tt := range expected {
+		sym := symbols[i]
+		if sym.Name != tt.name || sym.Kind != tt.kind || len(sym.Children) != len(tt.children) {
+			t.Errorf("wrong symbol %d. got=%+v", i, sym)
+			continue
+		}
+		for j, child := range tt.children {
+			if sym.Children[j].Name != child {
+				t.Errorf("wrong child of %s. want=%q, got=%q", tt.name, child, sym.Children[j].Name)
+			}
+		}
+	}
+	if symbols[0].Range.End.Line != 2 {
+		t.Errorf("wrong range of add. got=%+v", symbols[0].Range)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := &scriptedClient{}
+	before := c.request("textDocument/hover", positionParams(0, 0))
+	c.request("initialize", map[string]interface{}{})
+	unknown := c.request("workspace/unknown", nil)
+	notOpen := c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "file:///missing.aq"},
+		"position":     map[string]interface{}{"line": 0, "character": 0},
+	})
+	endSession(c)
+	msgs := c.run(t)
+
+	tests := []struct {
+		id   int
+		code int
+	}{
+		{before, codeServerNotInitialized},
+		{unknown, codeMethodNotFound},
+		{notOpen, codeInvalidParams},
+	}
+	for _, tt := range tests {
+		resp := findResponse(t, msgs, tt.id)
+		if resp.Error == nil || resp.Error.Code != tt.code {
+			t.Errorf("request %d: wrong error. want code %d, got=%+v", tt.id, tt.code, resp.Error)
+		}
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := &scriptedClient{}
+	c.request("initialize", map[string]interface{}{})
+	c.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := NewServer(&c.in, &out).Run(); err == nil {
+		t.Errorf("expected error when exiting without shutdown")
+	}
+}
+
+func TestPositionUTF16(t *testing.T) {
+	// 「あ」は1単位、絵文字はサロゲートペアで2単位
+	doc := newDocument(testURI, 1, "let s = \"あ😀\"; s\nx")
+
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{Line: 0, Character: 0}},
+		{len("let s = \"あ"), Position{Line: 0, Character: 10}},
+		{len("let s = \"あ😀"), Position{Line: 0, Character: 12}},
+		{len("let s = \"あ😀\"; s\n"), Position{Line: 1, Character: 0}},
+	}
+	for _, tt := range tests {
+		got := doc.position(tt.offset)
+		if got != tt.expected {
+			t.Errorf("position(%d) wrong. want=%+v, got=%+v", tt.offset, tt.expected, got)
+		}
+		if back := doc.offset(got); back != tt.offset {
+			t.Errorf("offset(%+v) wrong. want=%d, got=%d", got, tt.offset, back)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	c := &scriptedClient{}
+	startSession(c, "import \"lib/util.aq\" as util;\nexport let run = fn() { util.main() };\n")
+	hover := c.request("textDocument/hover", positionParams(1, 25)) // util.main() の util
+	symbolsID := c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": testURI}})
+	endSession(c)
+	msgs := c.run(t)
+
+	var h *Hover
+	if err := json.Unmarshal(findResponse(t, msgs, hover).Result, &h); err != nil {
+		t.Fatalf("invalid hover result: %s", err)
+	}
+	if h == nil || !strings.Contains(h.Contents.Value, `import "lib/util.aq" as util`) {
+		t.Errorf("wrong hover. got=%+v", h)
+	}
+
+	var symbols []DocumentSymbol
+	if err := json.Unmarshal(findResponse(t, msgs, symbolsID).Result, &symbols); err != nil {
+		t.Fatalf("invalid documentSymbol result: %s", err)
+	}
+	if len(symbols) != 2 || symbols[0].Name != "util" || symbols[0].Kind != SymbolModule ||
+		symbols[1].Name != "run" || symbols[1].Kind != SymbolFunction {
+		t.Errorf("wrong symbols. got=%+v", symbols)
+	}
+}
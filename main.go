@@ -1,14 +1,102 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"github.com/takeru-a/golang_interpreterlang/repl"
 	"os"
+	"path/filepath"
+
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/repl"
 )
 
+const usage = `usage:
+  aquamarine [flags]                         REPLを起動
+  aquamarine [flags] run <file.aq> [args...] スクリプトを実行 (- で標準入力)
+  aquamarine [flags] <file.aq> [args...]     スクリプトを実行 (シバン用)
+  aquamarine [flags] -e '<expr>' [args...]   式を実行して結果を表示
+  aquamarine fmt [-w] [-d] [files...]        ソースを整形 (-w で書き換え、-d で差分を表示)
+  aquamarine lint [-json] [files...]         実行せずに問題を検査
+  aquamarine lsp                             標準入出力で言語サーバーを起動
+
+flags:
+`
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	expr := flag.String("e", "", "実行する式")
+	engine := flag.String("engine", repl.EngineEval, "実行エンジン (eval または vm)")
+	allow := flag.String("allow", defaultAllow, "組み込み関数に許可する権限 (io,time,fs,env,proc の並び、all または none)")
+	modulePath := flag.String("path", os.Getenv("AQUAMARINE_PATH"), "importするモジュールを探すディレクトリ (区切りは "+string(filepath.ListSeparator)+")")
+	flag.Parse()
+	args := flag.Args()
+
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(os.Stderr, "unknown engine: %s\n", *engine)
+		os.Exit(exitUsage)
+	}
+	host := newHost(*allow, *modulePath)
+
+	switch {
+	case *expr != "":
+		os.Exit(runExpr(*expr, args, *engine, host))
+	case len(args) > 0 && args[0] == "fmt":
+		os.Exit(runFormat(args[1:]))
+	case len(args) > 0 && args[0] == "lint":
+		os.Exit(runLint(args[1:]))
+	case len(args) > 0 && args[0] == "lsp":
+		os.Exit(runLSP())
+	case len(args) > 0 && args[0] == "run":
+		runFlags := flag.NewFlagSet("run", flag.ExitOnError)
+		runEngine := runFlags.String("engine", *engine, "実行エンジン (eval または vm)")
+		runAllow := runFlags.String("allow", *allow, "組み込み関数に許可する権限")
+		runPath := runFlags.String("path", *modulePath, "importするモジュールを探すディレクトリ")
+		runFlags.Parse(args[1:])
+		if runFlags.NArg() < 1 {
+			flag.Usage()
+			os.Exit(exitUsage)
+		}
+		os.Exit(runFile(runFlags.Arg(0), runFlags.Args()[1:], *runEngine, newHost(*runAllow, *runPath)))
+	case len(args) > 0:
+		os.Exit(runFile(args[0], args[1:], *engine, host))
+	case !isTerminal(os.Stdin):
+		// パイプで渡されたプログラムを実行
+		os.Exit(runFile("-", nil, *engine, host))
+	}
 
 	fmt.Printf("Hello! This is the Aquamarine programming language!\n")
 	fmt.Printf("\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, *engine, host)
+}
+
+// 既定で許可する権限 (ファイルと環境変数は -allow で許可する)
+const defaultAllow = "io,time,proc"
+
+// -allowと-pathからスクリプトの外の世界を作る (誤りがあれば終了する)
+func newHost(allow, modulePath string) *object.Host {
+	grants, err := object.ParseCapabilities(allow)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	var searchPath []string
+	for _, dir := range filepath.SplitList(modulePath) {
+		if dir != "" {
+			searchPath = append(searchPath, dir)
+		}
+	}
+	return &object.Host{Grants: grants, Modules: object.NewModules(searchPath...)}
+}
+
+// 端末かどうかを判定
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/repl"
+)
+
+// -eの表示はどちらのエンジンでも同じになる
+func TestRunExprEngines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + 2`, "3\n"},
+		{`"a" + "b"`, "\"ab\"\n"},
+		{`let x = 41 + 1`, ""},
+		{`let x = 41 + 1; x`, "42\n"},
+		{`for (k in {"a": 1}) { output(k) }`, "a\n"},
+		{`while (false) {}`, ""},
+		{`let i = 0; while (i < 3) { i += 1 }`, ""},
+		{`if (false) { 1 }`, ""},
+		{`[1, "b"]`, "[1, \"b\"]\n"},
+	}
+
+	for _, engine := range []string{repl.EngineEval, repl.EngineVM} {
+		for _, tt := range tests {
+			var out strings.Builder
+			host := &object.Host{Grants: object.CapIO, Stdout: &out, Modules: object.NewModules()}
+
+			if code := runExpr(tt.input, nil, engine, host); code != exitOK {
+				t.Errorf("%s: %s: wrong exit code. got=%d", engine, tt.input, code)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("%s: %s: wrong output. expected=%q, got=%q", engine, tt.input, tt.expected, out.String())
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	exec  *Execution // 実行中の状態
+	host  *Host      // 外の世界 (nilなら制限しない)
 }
 
 func NewEnvironment() *Environment {
@@ -13,9 +15,28 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.exec = outer.exec
+	env.host = outer.host
 	return env
 }
 
+func (e *Environment) Execution() *Execution {
+	return e.exec
+}
+
+func (e *Environment) SetExecution(x *Execution) {
+	e.exec = x
+}
+
+func (e *Environment) Host() *Host {
+	return e.host
+}
+
+// 組み込み関数が使うホストを設定する (評価を始める前に呼ぶ)
+func (e *Environment) SetHost(h *Host) {
+	e.host = h
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -28,3 +49,15 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// 一番内側にある既存の変数を書き換える
+// 変数が見つからなければfalseを返す
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
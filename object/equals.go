@@ -0,0 +1,73 @@
+package object
+
+// 2つのオブジェクトが等しいか (==, != やハッシュのキーの比較で使う)
+//
+// 数値・文字列・真偽値は値で比較し、整数と浮動小数点数は数値として比較する。
+// 配列とハッシュは要素を再帰的に比較し、関数などそれ以外は同じオブジェクトのときだけ等しい。
+func Equals(a, b Object) bool {
+	return equals(a, b, map[[2]Object]bool{})
+}
+
+// seenは比較中の組 (自分自身を含む配列などで無限に再帰しないようにする)
+func equals(a, b Object, seen map[[2]Object]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *NULL:
+		_, ok := b.(*NULL)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if seen[[2]Object{a, b}] {
+			return true
+		}
+		seen[[2]Object{a, b}] = true
+
+		for i := range a.Elements {
+			if !equals(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		if seen[[2]Object{a, b}] {
+			return true
+		}
+		seen[[2]Object{a, b}] = true
+
+		for _, pair := range a.Items() {
+			other, ok := b.Lookup(pair.Key.(Hashable))
+			if !ok || !equals(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a == b
+}
@@ -0,0 +1,110 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 組み込み関数の権限のまとまり
+type Capability uint
+
+const (
+	CapIO   Capability = 1 << iota // 出力 (output)
+	CapTime                        // 時計 (now)
+	CapFS                          // ファイル (readFile, writeFile)
+	CapEnv                         // 環境変数 (getenv)
+	CapProc                        // プロセスの引数 (argc, argv)
+
+	CapNone Capability = 0
+	CapAll             = CapIO | CapTime | CapFS | CapEnv | CapProc
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapIO, "io"},
+	{CapTime, "time"},
+	{CapFS, "fs"},
+	{CapEnv, "env"},
+	{CapProc, "proc"},
+}
+
+// すべての権限を含んでいるか
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+// "io,time" のような名前の並び
+func (c Capability) String() string {
+	var names []string
+	for _, cn := range capabilityNames {
+		if c.Has(cn.cap) {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// "io,time" のような名前の並びを読む ("all" はすべて、"none" や空文字列は無し)
+func ParseCapabilities(s string) (Capability, error) {
+	var c Capability
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			c |= CapAll
+			continue
+		}
+
+		found := false
+		for _, cn := range capabilityNames {
+			if cn.name == name {
+				c |= cn.cap
+				found = true
+				break
+			}
+		}
+		if !found {
+			return CapNone, fmt.Errorf("unknown capability: %s", name)
+		}
+	}
+	return c, nil
+}
+
+// スクリプトから見た外の世界
+// インタプリタごとに、許可した権限と出力先・時計を差し替えられる
+type Host struct {
+	Grants Capability
+	Stdout io.Writer        // outputの出力先 (nilなら標準出力)
+	Now    func() time.Time // 時計 (nilなら実際の時刻)
+	Args   []string         // argc, argvで参照するコマンドライン引数
+
+	Modules *Modules // importで読み込んだモジュール (nilならimportできない)
+}
+
+// 権限をすべて許可した、標準出力と実際の時計を使うホスト
+// (ホストを設定せずに評価したときに使う)
+var Unrestricted = &Host{Grants: CapAll}
+
+func (h *Host) Output() io.Writer {
+	if h.Stdout == nil {
+		return os.Stdout
+	}
+	return h.Stdout
+}
+
+func (h *Host) Time() time.Time {
+	if h.Now == nil {
+		return time.Now()
+	}
+	return h.Now()
+}
@@ -0,0 +1,21 @@
+package object
+
+import "context"
+
+// 実行の制限 (0は無制限、MaxDepthだけは0なら既定値)
+type Limits struct {
+	MaxSteps         int64 // 評価するノードの数
+	MaxDepth         int   // 関数呼び出しの深さ
+	MaxStringLen     int   // 文字列の長さ (バイト)
+	MaxCollectionLen int   // 配列・ハッシュの要素数
+}
+
+// 1回の実行の状態
+// 環境から環境へ受け渡され、評価器が制限を超えていないかを調べる
+type Execution struct {
+	Context context.Context // 取り消し・期限 (nilなら無し)
+	Limits  Limits
+	Steps   int64 // 評価したノードの数
+	Depth   int   // 今の関数呼び出しの深さ
+	Host    *Host // 組み込み関数が使う外の世界 (nilなら制限しない)
+}
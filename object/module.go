@@ -0,0 +1,89 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// import で読み込んだモジュール
+type Module struct {
+	Path    string          // 読み込んだファイルのパス
+	Env     *Environment    // モジュールの一番外側の環境
+	Exports map[string]bool // exportした名前
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + quote(m.Path) + ">" }
+
+// exportした名前の値 (exportしていなければfalse)
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// exportした名前の一覧 (名前順)
+func (m *Module) Names() []string {
+	names := make([]string, 0, len(m.Exports))
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 読み込んだモジュールの一覧 (インタプリタごとに1つ)
+// 同じファイルは一度だけ評価し、2回目からは同じモジュールを返す
+type Modules struct {
+	SearchPath []string // importするファイルが見つからないときに探すディレクトリ
+
+	loaded  map[string]*Module // 絶対パスごとの読み込み済みのモジュール
+	loading []loadingModule    // 読み込み中のモジュール (循環の検出用)
+}
+
+type loadingModule struct {
+	key  string // 絶対パス
+	path string // エラーに表示するパス
+}
+
+func NewModules(searchPath ...string) *Modules {
+	return &Modules{SearchPath: searchPath, loaded: map[string]*Module{}}
+}
+
+// 読み込み済みのモジュール
+func (ms *Modules) Lookup(key string) (*Module, bool) {
+	m, ok := ms.loaded[key]
+	return m, ok
+}
+
+// モジュールの読み込みを始める
+// 読み込み中のモジュールをもう一度読み込もうとしたときは循環のエラーを返す
+func (ms *Modules) Begin(key, path string) error {
+	for i, l := range ms.loading {
+		if l.key == key {
+			chain := make([]string, 0, len(ms.loading)-i+1)
+			for _, l := range ms.loading[i:] {
+				chain = append(chain, l.path)
+			}
+			chain = append(chain, path)
+			return fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	ms.loading = append(ms.loading, loadingModule{key: key, path: path})
+	return nil
+}
+
+// モジュールの読み込みを終える (mがnilなら失敗したので覚えない)
+func (ms *Modules) Finish(key string, m *Module) {
+	for i := len(ms.loading) - 1; i >= 0; i-- {
+		if ms.loading[i].key == key {
+			ms.loading = append(ms.loading[:i], ms.loading[i+1:]...)
+			break
+		}
+	}
+	if m != nil {
+		ms.loaded[key] = m
+	}
+}
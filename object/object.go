@@ -3,28 +3,56 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/code"
+	"github.com/takeru-a/golang_interpreterlang/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ = "FLOAT"
 	BOOLEAN_OBJ = "BOOLEAN"
 	NULL_OBJ = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ = "BREAK"
+	CONTINUE_OBJ = "CONTINUE"
 	ERROR_OBJ = "ERROR"
 	FUNCTION_OBJ = "FUNCTION"
 	STRING_OBJ = "STRING"
 	BUILTIN_OBJ = "BUILTIN"
 	NULLSTRING_OBJ = "NULLSTRING"
+	ARRAY_OBJ = "ARRAY"
+	HASH_OBJ = "HASH"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ = "CLOSURE"
+	MODULE_OBJ = "MODULE"
 )
 
 type Object interface {
 	Type() ObjectType
-	Inspect() string
+	Inspect() string // REPLなどで値を確認するための表示 (文字列は引用符付き)
+}
+
+// 利用者向けの表示 (output関数や文字列の埋め込み式で使う) を持つオブジェクト
+type Displayer interface {
+	Display() string
+}
+
+// 利用者向けの文字列表現を返す
+// Displayを持たないオブジェクトはInspectと同じになる
+func Display(obj Object) string {
+	if d, ok := obj.(Displayer); ok {
+		return d.Display()
+	}
+	return obj.Inspect()
 }
 
 // 整数
@@ -34,6 +62,38 @@ type Integer struct {
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value ) }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// 浮動小数点数
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// 整数と等しい値は整数と同じキーになる ({1: "a"}[1.0] で取り出せる)
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+func (f *Float) Inspect() string {
+	// 整数と区別できるよう、小数点か指数を必ず付ける
+	abs := math.Abs(f.Value)
+	var s string
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		s = strconv.FormatFloat(f.Value, 'g', -1, 64)
+	} else {
+		s = strconv.FormatFloat(f.Value, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
 
 // 真偽値
 type Boolean struct {
@@ -42,6 +102,13 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value )}
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
 
 // NULL
 type NULL struct{}
@@ -53,6 +120,7 @@ type NULLSTRING struct{}
 
 func (ns *NULLSTRING) Type() ObjectType { return NULLSTRING_OBJ }
 func (ns *NULLSTRING) Inspect() string { return "" }
+func (ns *NULLSTRING) Display() string { return "" }
 
 // Return
 type ReturnValue struct {
@@ -62,22 +130,83 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+// Break (ループを抜ける合図)
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string { return "break" }
+
+// Continue (ループの次の繰り返しへ進む合図)
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string { return "continue" }
+
 // Error
 type Error struct {
 	Message string
+	Pos token.Position // エラーが発生した位置
+	Stack []StackFrame // 呼び出し履歴 (エラーが起きた関数から外側へ)
+}
+
+// 呼び出し履歴の1段
+type StackFrame struct {
+	Function string         // 呼び出された関数の名前
+	Pos      token.Position // 呼び出した位置
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// 呼び出し履歴を付けた表示
+//
+//	ERROR: main.aq:2:7: identifier not found: foo
+//	    at add (main.aq:5:1)
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+	for i := 0; i < len(e.Stack); {
+		frame := e.Stack[i]
+		out.WriteString("\n    at " + frame.Function + " (" + frame.Pos.String() + ")")
+
+		// 再帰で同じ呼び出しが続くときはまとめる
+		same := i + 1
+		for same < len(e.Stack) && e.Stack[same] == frame {
+			same++
+		}
+		if repeated := same - i - 1; repeated > 0 {
+			out.WriteString(fmt.Sprintf("\n    ... repeated %d more times", repeated))
+		}
+		i = same
+	}
+
+	return out.String()
+}
+
+// Goのerrorとしても扱えるようにする
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+	return e.Message
+}
 
 // Function
 type Function struct {
 	Parameters []*ast.Indetifier
 	Body *ast.BlockStatement
 	Env *Environment
+	Name string // 関数名 (無名関数は空)
 }
 
 func (fn *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (fn *Function) DisplayName() string { return displayName(fn.Name) }
 func (fn *Function) Inspect() string {
 	var out bytes.Buffer
 
@@ -96,20 +225,217 @@ func (fn *Function) Inspect() string {
 	return out.String()
 }
 
+// エラーメッセージなどに使う関数名
+func displayName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
 // 文字列
 type String struct {
 	Value string
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string { return s.Value }
+func (s *String) Inspect() string { return quote(s.Value) }
+func (s *String) Display() string { return s.Value }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
 
 // 組み込み関数
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// 外の世界を使う組み込み関数はFnの代わりにHostFnを使う
+	// 呼び出すにはホストがCapabilityの権限を許可している必要がある
+	Name       string
+	Capability Capability
+	HostFn     func(host *Host, args ...Object) Object
 }
 
-func (b *Builtin) Type() ObjectType { return BOOLEAN_OBJ }
+// ホストの権限を調べて呼び出す (hostがnilなら制限しない)
+func (b *Builtin) Call(host *Host, args ...Object) Object {
+	if b.HostFn == nil {
+		return b.Fn(args...)
+	}
+	if host == nil {
+		host = Unrestricted
+	}
+	if !host.Grants.Has(b.Capability) {
+		return &Error{Message: fmt.Sprintf("permission denied: %s requires the %s capability", b.Name, b.Capability)}
+	}
+	return b.HostFn(host, args...)
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string { return "builtin function" }
- 
\ No newline at end of file
+ 
+// 配列
+type Array struct {
+	Elements []Object
+}
+
+func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
+func (ao *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range ao.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// ハッシュのキー
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// ハッシュのキーに使えるオブジェクト
+// Equalsで等しいオブジェクトは同じHashKeyを返す
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// ハッシュ (挿入順を保持する)
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// キーに対応する組を探す (キーはEqualsで比較する)
+func (h *Hash) Lookup(key Hashable) (HashPair, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	if !ok || !Equals(pair.Key, key) {
+		return HashPair{}, false
+	}
+	return pair, true
+}
+
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.Pairs[key]; !ok {
+		return
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.order {
+		if k == key {
+			h.order = append(h.order[:i:i], h.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// 挿入順の組
+func (h *Hash) Items() []HashPair {
+	items := make([]HashPair, 0, len(h.order))
+	for _, key := range h.order {
+		items = append(items, h.Pairs[key])
+	}
+	return items
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Items() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// コンパイル済みの関数
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Name          string                 // 関数名 (無名関数は空)
+	Positions     map[int]token.Position // 命令の位置 -> ソース上の位置
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) DisplayName() string { return displayName(cf.Name) }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// クロージャ (コンパイル済みの関数と自由変数)
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// 文字列をソースに書ける形 ("..."でエスケープ付き) にする
+func quote(s string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '$' && strings.HasPrefix(s[i+1:], "{"):
+			out.WriteString(`\$`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case unicode.IsPrint(r):
+			out.WriteRune(r)
+		default:
+			fmt.Fprintf(&out, "\\u{%X}", r)
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
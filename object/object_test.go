@@ -0,0 +1,294 @@
+package object
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+// 同じ内容の文字列は同じハッシュキーになる
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+// ハッシュは挿入順を保持する
+func TestHashOrder(t *testing.T) {
+	h := NewHash()
+	for _, k := range []string{"c", "a", "b"} {
+		key := &String{Value: k}
+		h.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	}
+	h.Delete((&String{Value: "a"}).HashKey())
+	d := &String{Value: "d"}
+	h.Set(d.HashKey(), HashPair{Key: d, Value: d})
+
+	if h.Inspect() != `{"c": "c", "b": "b", "d": "d"}` {
+		t.Errorf("wrong order. got=%q", h.Inspect())
+	}
+}
+
+// Assignは一番内側の既存の変数を書き換える
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if _, ok := inner.Assign("x", &Integer{Value: 2}); !ok {
+		t.Fatalf("Assign failed for existing outer variable")
+	}
+	if _, ok := inner.store["x"]; ok {
+		t.Errorf("Assign created a new variable in the inner environment")
+	}
+	if val, _ := outer.Get("x"); val.(*Integer).Value != 2 {
+		t.Errorf("outer x not updated. got=%s", val.Inspect())
+	}
+
+	inner.Set("x", &Integer{Value: 3})
+	inner.Assign("x", &Integer{Value: 4})
+	if val, _ := outer.Get("x"); val.(*Integer).Value != 2 {
+		t.Errorf("outer x updated through shadowing variable. got=%s", val.Inspect())
+	}
+
+	if _, ok := inner.Assign("y", &Integer{Value: 1}); ok {
+		t.Errorf("Assign succeeded for undeclared variable")
+	}
+	if _, ok := outer.Get("y"); ok {
+		t.Errorf("Assign created undeclared variable")
+	}
+}
+
+// 浮動小数点数は整数と区別できる形で表示する
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{3, "3.0"},
+		{-0.5, "-0.5"},
+		{1e6, "1000000.0"},
+		{1e21, "1e+21"},
+		{1.5e-7, "1.5e-07"},
+		{0, "0.0"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+// 文字列のInspectは引用符付き、Displayはそのまま
+func TestStringInspect(t *testing.T) {
+	tests := []struct {
+		value           string
+		expectedInspect string
+	}{
+		{"abc", `"abc"`},
+		{"say \"hi\"\n", `"say \"hi\"\n"`},
+		{`C:\path`, `"C:\\path"`},
+		{"${x} $5", `"\${x} $5"`},
+		{"アクア\u0007", `"アクア\u{7}"`},
+	}
+
+	for _, tt := range tests {
+		s := &String{Value: tt.value}
+		if s.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expectedInspect, s.Inspect())
+		}
+		if Display(s) != tt.value {
+			t.Errorf("wrong Display. expected=%q, got=%q", tt.value, Display(s))
+		}
+	}
+
+	arr := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+	if Display(arr) != `["a", 1]` {
+		t.Errorf("wrong Display for array. got=%q", Display(arr))
+	}
+}
+
+// 呼び出し履歴付きのエラー表示
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{
+		Message: "identifier not found: foo",
+		Pos:     token.Position{Filename: "main.aq", Line: 2, Column: 7},
+		Stack: []StackFrame{
+			{Function: "add", Pos: token.Position{Filename: "main.aq", Line: 5, Column: 1}},
+			{Function: "<anonymous>", Pos: token.Position{Filename: "main.aq", Line: 9, Column: 3}},
+		},
+	}
+
+	expected := "ERROR: main.aq:2:7: identifier not found: foo\n" +
+		"    at add (main.aq:5:1)\n" +
+		"    at <anonymous> (main.aq:9:3)"
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expected, err.Traceback())
+	}
+
+	// 再帰で続く同じ呼び出しはまとめる
+	inner := StackFrame{Function: "f", Pos: token.Position{Filename: "main.aq", Line: 1, Column: 17}}
+	outer := StackFrame{Function: "f", Pos: token.Position{Filename: "main.aq", Line: 2, Column: 1}}
+	err.Stack = []StackFrame{inner, inner, inner, outer}
+	expected = "ERROR: main.aq:2:7: identifier not found: foo\n" +
+		"    at f (main.aq:1:17)\n" +
+		"    ... repeated 2 more times\n" +
+		"    at f (main.aq:2:1)"
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expected, err.Traceback())
+	}
+}
+
+// 等しさの比較
+func TestEquals(t *testing.T) {
+	fn := &Function{}
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+	cyclic2 := &Array{}
+	cyclic2.Elements = []Object{cyclic2}
+
+	hash := func(pairs ...Object) *Hash {
+		h := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i].(Hashable).HashKey(), HashPair{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return h
+	}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1.0}, true},
+		{&Float{Value: 1.5}, &Integer{Value: 1}, false},
+		{&Float{Value: math.NaN()}, &Float{Value: math.NaN()}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "1"}, &Integer{Value: 1}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Integer{Value: 1}, false},
+		{&NULL{}, &NULL{}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}},
+			&Array{Elements: []Object{&Float{Value: 1}, &String{Value: "a"}}}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{}}, false},
+		{arr, arr, true},
+		{cyclic, cyclic2, true},
+		{hash(&String{Value: "a"}, &Integer{Value: 1}, &Integer{Value: 2}, arr),
+			hash(&Float{Value: 2}, &Array{Elements: []Object{&Integer{Value: 1}}}, &String{Value: "a"}, &Integer{Value: 1}), true},
+		{hash(&String{Value: "a"}, &Integer{Value: 1}), hash(&String{Value: "a"}, &Integer{Value: 2}), false},
+		{hash(&String{Value: "a"}, &Integer{Value: 1}), hash(&String{Value: "b"}, &Integer{Value: 1}), false},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equals(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Equals(%s, %s) wrong. expected=%t, got=%t", i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+// 等しい数値は同じハッシュのキーになる
+func TestNumberHashKey(t *testing.T) {
+	if (&Float{Value: 2.0}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("2.0 and 2 have different hash keys")
+	}
+	if (&Float{Value: 2.5}).HashKey() == (&Float{Value: 3.5}).HashKey() {
+		t.Errorf("2.5 and 3.5 have same hash keys")
+	}
+
+	h := NewHash()
+	one := &Integer{Value: 1}
+	h.Set(one.HashKey(), HashPair{Key: one, Value: one})
+	if _, ok := h.Lookup(&Float{Value: 1.0}); !ok {
+		t.Errorf("1.0 not found in hash with key 1")
+	}
+	if _, ok := h.Lookup(&Boolean{Value: true}); ok {
+		t.Errorf("true found in hash with key 1")
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Capability
+		str      string
+	}{
+		{"", CapNone, "none"},
+		{"none", CapNone, "none"},
+		{"io", CapIO, "io"},
+		{"time, io", CapIO | CapTime, "io,time"},
+		{"fs,env,proc", CapFS | CapEnv | CapProc, "fs,env,proc"},
+		{"all", CapAll, "io,time,fs,env,proc"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCapabilities(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if c != tt.expected {
+			t.Errorf("%q: wrong capability. want=%d, got=%d", tt.input, tt.expected, c)
+		}
+		if c.String() != tt.str {
+			t.Errorf("%q: wrong String(). want=%q, got=%q", tt.input, tt.str, c.String())
+		}
+	}
+
+	if _, err := ParseCapabilities("io,net"); err == nil || err.Error() != "unknown capability: net" {
+		t.Errorf("expected unknown capability error. got=%v", err)
+	}
+}
+
+// 許可していない権限の組み込み関数はエラーになる
+func TestBuiltinCall(t *testing.T) {
+	called := false
+	b := &Builtin{
+		Name:       "clock",
+		Capability: CapTime,
+		HostFn: func(host *Host, args ...Object) Object {
+			called = true
+			return &Integer{Value: host.Time().Unix()}
+		},
+	}
+
+	result := b.Call(&Host{Grants: CapIO})
+	if errObj, ok := result.(*Error); !ok || errObj.Message != "permission denied: clock requires the time capability" {
+		t.Errorf("expected permission error. got=%T(%+v)", result, result)
+	}
+	if called {
+		t.Errorf("function was called without the capability")
+	}
+
+	host := &Host{Grants: CapTime, Now: func() time.Time { return time.Unix(42, 0) }}
+	if n, ok := b.Call(host).(*Integer); !ok || n.Value != 42 {
+		t.Errorf("expected 42 from injected clock. got=%+v", b.Call(host))
+	}
+
+	// ホストが無ければ制限しない
+	if _, ok := b.Call(nil).(*Integer); !ok {
+		t.Errorf("expected call without host to succeed")
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/takeru-a/golang_interpreterlang/token"
+)
+
+// エラーのあった文が予約語の書き間違いで始まっていれば、最後のエラーを
+// その識別子の位置のエラーに置き換えてヒントを付ける
+// (retrun 5; の 5 ではなく retrun を報告する)
+func (p *Parser) addKeywordHint(start token.Token) {
+	if start.Type != token.INDENT || len(p.diagnostics) == 0 {
+		return
+	}
+	last := &p.diagnostics[len(p.diagnostics)-1]
+	if last.Pos.Offset < start.Pos.Offset {
+		return
+	}
+
+	if keyword := suggestKeyword(start.Literal); keyword != "" {
+		last.Pos, last.End = start.Pos, start.End
+		last.Message = fmt.Sprintf("unexpected identifier %s", start.Literal)
+		last.Hint = fmt.Sprintf("did you mean `%s`?", keyword)
+	}
+}
+
+// 識別子に近い予約語を返す (無ければ空文字列)
+// 2文字の予約語 (if, fn, in) は普通の識別子と紛らわしいので候補にしない
+func suggestKeyword(ident string) string {
+	best, bestDistance := "", 2
+	for _, keyword := range token.Keywords() {
+		if len(keyword) < 3 {
+			continue
+		}
+		if d := editDistance(ident, keyword); d < bestDistance {
+			best, bestDistance = keyword, d
+		}
+	}
+	return best
+}
+
+// 編集距離 (隣り合う文字の入れ替えも1回と数える)
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(s)][len(t)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
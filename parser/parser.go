@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
 	"github.com/takeru-a/golang_interpreterlang/lexer"
 	"github.com/takeru-a/golang_interpreterlang/token"
 )
@@ -13,34 +14,63 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y  x += y
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQALS       // ==
-	LESSGREATER // >  <
+	LESSGREATER // >  <  >=  <=
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // *  /  %
 	PREFIX      // -x !x
+	POWER       // x ** y (右結合で、-x ** yは-(x ** y))
 	CALL        // myFunction(x)
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.OR:       LOGICAL_OR,
+	token.AND:      LOGICAL_AND,
 	token.EQ:       EQALS,
 	token.NOT_EQ:   EQALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LT_EQ:    LESSGREATER,
+	token.GT_EQ:    LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type Parser struct {
-	l         *lexer.Lexer //字句解析器
-	errors    []string     // errormessage
+	l           *lexer.Lexer      //字句解析器
+	diagnostics []diag.Diagnostic // 構文エラー
 	curToken  token.Token  //現在のトーク
 	peekToken token.Token  //次のトークン
 
 	prefixParseFns map[token.TokenType]prefixParseFn // 前置構文解析関数
 	infixParseFns  map[token.TokenType]infixParseFn  // 中置構文解析関数
+
+	loopDepth int // 解析中のループの深さ (break, continueの検査用)
+	stmtIf    bool // 文の先頭のif (値を使わないif) を解析し始めるときtrue
+	stmtIfExp *ast.IfExpression // 解析中の文の先頭のif
+
+	lexerErrors int // 取り込み済みの字句解析のエラーの数
+
+	panicking bool // エラーの後、次の文まで読み飛ばすまでの間true (その間のエラーは報告しない)
+	depth     int  // 現在のトークンまでの { の深さ (読み飛ばす範囲の判定に使う)
+	prevEnd   token.Position // 1つ前のトークンの終了位置
 }
 
 type (
@@ -59,7 +89,7 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l}
 	// curToken, peekTokenを初期化
 	p.nextToken()
 	p.nextToken()
@@ -68,6 +98,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.INDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -76,6 +107,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionStatement)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_PART, p.parseInterpolatedString)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	//　中置構文解析関数の設定
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -87,11 +121,33 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
 
 	return p
 }
 
+// 中置演算子のトークンの優先度 (中置演算子でなければLOWEST)
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 // 次のトークンの優先度を返却する
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
@@ -108,20 +164,78 @@ func (p *Parser) curPrecedence() int {
 	return LOWEST
 }
 
+// 構文エラー ("位置: メッセージ" の形式)
 func (p *Parser) Errors() []string {
-	return p.errors
+	return diag.Strings(p.diagnostics)
+}
+
+// 構文エラー (範囲やヒントなどの情報付き)
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diagnostics
+}
+
+// トークンの位置に構文エラーを追加
+func (p *Parser) errorAt(tok token.Token, format string, a ...interface{}) {
+	// 字句解析のエラー (閉じていない文字列など) で入力の終わりまで読んだときは、
+	// その後のエラーは最初のエラーの影響なので報告しない
+	if tok.Type == token.EOF && p.lexerErrors > 0 {
+		p.panicking = true
+		return
+	}
+	p.syntaxError(tok.Pos, tok.End, format, a...)
+}
+
+// 構文エラーを追加し、次の文まで読み飛ばす
+func (p *Parser) syntaxError(pos, end token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.addError(pos, end, format, a...)
+}
+
+// 構文としては読めたが正しくない箇所のエラーを追加 (読み飛ばしは不要)
+func (p *Parser) invalidAt(pos, end token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.addError(pos, end, format, a...)
+}
+
+func (p *Parser) addError(pos, end token.Position, format string, a ...interface{}) {
+	p.diagnostics = append(p.diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Pos:      pos,
+		End:      end,
+		Message:  fmt.Sprintf(format, a...),
+	})
 }
 
 // errormessageを追加
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 // tokenを更新する
 func (p *Parser) nextToken() {
+	p.prevEnd = p.curToken.End
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		if p.depth > 0 {
+			p.depth--
+		}
+	}
+
+	// 字句解析のエラーも構文エラーとして報告する
+	if diagnostics := p.l.Diagnostics(); len(diagnostics) > p.lexerErrors {
+		p.diagnostics = append(p.diagnostics, diagnostics[p.lexerErrors:]...)
+		p.lexerErrors = len(diagnostics)
+	}
 }
 
 // tokenに応じて、適切なステートメントの構文解析関数を呼び出す
@@ -131,7 +245,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	// 終端に届くまでforを回す
 	for p.curToken.Type != token.EOF {
+		start := p.curToken
 		stmt := p.parseStatement()
+		if p.recoverStatement(start, 0) {
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -140,6 +258,74 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// 文の構文解析でエラーがあれば、次の文の先頭まで読み飛ばしてtrueを返す
+// startは文の最初のトークン、depthは文を含むブロックの { の深さ
+func (p *Parser) recoverStatement(start token.Token, depth int) bool {
+	if !p.panicking {
+		p.checkStatementEnd()
+	}
+	if !p.panicking {
+		return false
+	}
+
+	p.addKeywordHint(start)
+	p.synchronize(start, depth)
+	p.panicking = false
+	return true
+}
+
+// 同じ行に続けて書く文は ; で区切る
+// (`retrun x` や `whlie (x) { ... }` のような予約語の書き間違いもここで見つかる)
+func (p *Parser) checkStatementEnd() {
+	if p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.RBRACE) {
+		return
+	}
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		return
+	}
+	if p.peekToken.Pos.Line != p.curToken.End.Line {
+		return
+	}
+	if p.peekTokenIs(token.ILLEGAL) {
+		p.errorAt(p.peekToken, "unexpected character %q", p.peekToken.Literal)
+		return
+	}
+	p.errorAt(p.peekToken, "unexpected %s after statement", p.peekToken.Type)
+	if p.prefixParseFns[p.peekToken.Type] != nil {
+		p.diagnostics[len(p.diagnostics)-1].Hint = "use ; to separate statements on the same line"
+	}
+}
+
+// 次の文の先頭まで読み飛ばす
+// ; の次、同じ深さの行頭のトークンや文の予約語、またはブロックを閉じる } で止まる
+func (p *Parser) synchronize(start token.Token, depth int) {
+	for !p.curTokenIs(token.EOF) {
+		atStart := p.curToken.Pos == start.Pos
+		newLine := p.curToken.Pos.Line > p.prevEnd.Line
+
+		switch {
+		case p.curTokenIs(token.RBRACE) && p.depth < depth:
+			return
+		case p.depth == depth && !atStart && (newLine || isStatementKeyword(p.curToken.Type)):
+			return
+		case p.depth == depth && p.curTokenIs(token.SEMICOLON):
+			p.nextToken()
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// 文の始まりになる予約語か
+func isStatementKeyword(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.IF, token.WHILE, token.FOR, token.BREAK, token.CONTINUE,
+		token.IMPORT, token.EXPORT:
+		return true
+	}
+	return false
+}
+
 // 文の構文解析
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
@@ -147,6 +333,16 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -170,6 +366,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -220,8 +420,11 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 // 前置構文がないときのエラーメッセージを追加
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		p.errorAt(p.curToken, "unexpected character %q", p.curToken.Literal)
+		return
+	}
+	p.errorAt(p.curToken, "expected an expression, got %s", t)
 }
 
 // 識別子式
@@ -256,7 +459,14 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
+	p.stmtIf = p.curTokenIs(token.IF)
+	p.stmtIfExp = nil
 	stmt.Expression = p.parseExpression(LOWEST)
+	// 文の先頭のifに演算子などが続くときは、ifの値を使う
+	if ifExp := p.stmtIfExp; ifExp != nil && stmt.Expression != ast.Expression(ifExp) {
+		p.checkValueIf(ifExp)
+	}
+	p.stmtIfExp = nil
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -271,8 +481,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.invalidAt(p.curToken.Pos, p.curToken.End, "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// 浮動小数点数リテラルの構文解析
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.invalidAt(p.curToken.Pos, p.curToken.End, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -304,6 +528,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedences := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// 右結合にする 2 ** 3 ** 2 => 2 ** (3 ** 2)
+		precedences--
+	}
 	p.nextToken()
 	// 中置演算子の右側の式を構文解析
 	expression.Right = p.parseExpression(precedences)
@@ -331,6 +559,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 // ifの構文
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
+	statement := p.stmtIf
+	p.stmtIf = false
 
 	if !p.expectPeek(token.LPAREN) {
 		return nil
@@ -359,23 +589,53 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		expression.Alternative = p.parseBlockStatement()
 	}
 
+	if statement {
+		p.stmtIfExp = expression
+	} else {
+		p.checkValueIf(expression)
+	}
+
 	return expression
 }
 
+// 値を使うifの中ではループを抜けられない
+// (let y = if (c) { break } のように、式の途中でループを抜けることになるため)
+func (p *Parser) checkValueIf(ifExp *ast.IfExpression) {
+	// ループの外ならbreak outside loopとして報告済み
+	if p.loopDepth == 0 {
+		return
+	}
+	ast.Inspect(ifExp, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.WhileStatement, *ast.ForStatement, *ast.FunctionLiteral:
+			return false
+		case *ast.BreakStatement, *ast.ContinueStatement:
+			p.invalidAt(n.Pos(), n.End(), "%s inside an if expression used as a value", n.TokenLiteral())
+		}
+		return true
+	})
+}
+
 // {}の中
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	depth := p.depth
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
+		if p.recoverStatement(start, depth) {
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -394,7 +654,11 @@ func (p *Parser) parseFunctionStatement() ast.Expression {
 		return nil
 	}
 
+	// 関数の中から外側のループは抜けられない
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return lit
 }
@@ -416,6 +680,10 @@ func (p *Parser) parseFunctionParameters() []*ast.Indetifier {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // Comma
+		// 最後の要素の後のカンマ (複数行に分けて書くとき)
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
 		p.nextToken() // indetifier
 		ident := &ast.Indetifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
@@ -431,36 +699,300 @@ func (p *Parser) parseFunctionParameters() []*ast.Indetifier {
 // 呼び出し式
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
+// カンマ区切りの式の並び (end で終わる)
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
 
-	// 引数なしの場合
-	if p.peekTokenIs(token.RPAREN) {
+	// 要素なしの場合
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // Comma
+		// 最後の要素の後のカンマ (複数行に分けて書くとき)
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
 }
 
 // 文字列
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
+
+// 埋め込み式を含む文字列 "a${x}b"
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for p.curTokenIs(token.TEMPLATE_PART) || p.curTokenIs(token.TEMPLATE_MID) {
+		str.Literals = append(str.Literals, p.curToken.Literal)
+		str.Parts = append(str.Parts, p.curToken)
+
+		// "${" の直後が "}" (入れ子の文字列の始まりのTEMPLATE_PARTは式になる)
+		if p.peekTokenIs(token.TEMPLATE_MID) || p.peekTokenIs(token.TEMPLATE_END) {
+			p.errorAt(p.peekToken, "empty expression in string interpolation")
+			return nil
+		}
+		p.nextToken()
+		str.Exprs = append(str.Exprs, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.TEMPLATE_MID) && !p.peekTokenIs(token.TEMPLATE_END) {
+			p.errorAt(p.peekToken, "expected } to close string interpolation, got %s", p.peekToken.Type)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	str.Literals = append(str.Literals, p.curToken.Literal)
+	str.Parts = append(str.Parts, p.curToken)
+	str.EndToken = p.curToken
+
+	return str
+}
+
+// 配列リテラル
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
+	return array
+}
+
+// 添字式
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+
+	return exp
+}
+
+// モジュールの名前の参照 util.name
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.INDENT) {
+		return nil
+	}
+	exp.Name = &ast.Indetifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// 代入式 (右結合)
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	switch left.(type) {
+	case *ast.Indetifier, *ast.IndexExpression:
+	default:
+		p.syntaxError(left.Pos(), left.End(), "cannot assign to %s", left.String())
+		return nil
+	}
+
+	exp := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   left,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+
+	return exp
+}
+
+// ハッシュリテラル
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+// whileの構文解析
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// forの構文解析 for (x in xs) { ... }
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.INDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Indetifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// ループ本体
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+	return body
+}
+
+// break, continueの構文解析
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	if p.loopDepth == 0 {
+		p.invalidAt(p.curToken.Pos, p.curToken.End, "%s outside loop", p.curToken.Literal)
+	}
+
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// import "path" as name
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	p.checkTopLevel()
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+	if !p.expectPeek(token.INDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Indetifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// export let name = value;
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	p.checkTopLevel()
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// importとexportはファイルの一番外側にだけ書ける
+func (p *Parser) checkTopLevel() {
+	if p.depth > 0 {
+		p.invalidAt(p.curToken.Pos, p.curToken.End, "%s is only allowed at the top level", p.curToken.Literal)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/diag"
 	"github.com/takeru-a/golang_interpreterlang/lexer"
 )
 
@@ -23,7 +24,7 @@ func TestLetStatements(t *testing.T) {
 
 	for _, tt := range tests {
 
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 
 		program := p.ParseProgram()
@@ -94,7 +95,7 @@ func TestReturnStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -121,7 +122,7 @@ func TestReturnStatements(t *testing.T) {
 
 func TestIdentifierExpression(t *testing.T){
 	input := "foobar;"
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t,p)
@@ -151,7 +152,7 @@ func TestIdentifierExpression(t *testing.T){
 func TestIntegerLiteralExpression(t *testing.T) {
 	
 	input := "5;"
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -196,7 +197,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 
 
 for _ , tt := range prefixTests {
-	l := lexer.New(tt.input)
+	l := lexer.New("", tt.input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -266,7 +267,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 
 
 for _ , tt := range infixTests {
-	l := lexer.New(tt.input)
+	l := lexer.New("", tt.input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -373,7 +374,7 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 func TestBooleanExpression(t *testing.T) {
 	
 	input := "false;"
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -449,9 +450,45 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-a ** 2 * b",
+			"((-(a ** 2)) * b)",
+		},
+		{
+			"a[0] ** f(b)",
+			"((a[0]) ** f(b))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
 	}
 	for _, tt := range tests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -468,7 +505,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 func TestIfExpression (t *testing.T) {
 	input := `if (x < y) { x }`
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -516,7 +553,7 @@ func TestIfExpression (t *testing.T) {
 func TestIfElseExpression (t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -574,7 +611,7 @@ func TestIfElseExpression (t *testing.T) {
 func TestFunctionLiteral (t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -627,7 +664,7 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		l := lexer.New(tt.input)
+		l := lexer.New("", tt.input)
 		p := New(l)
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
@@ -650,7 +687,7 @@ func TestFunctionParameterParsing(t *testing.T) {
 func TestCallExpressionParsing (t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -689,7 +726,7 @@ func TestCallExpressionParsing (t *testing.T) {
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world!";`
 
-	l := lexer.New(input)
+	l := lexer.New("", input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -704,3 +741,771 @@ func TestStringLiteralExpression(t *testing.T) {
 		t.Errorf("literal.Value not %q. got=%q", "hello world!", literal.Value)
 	}
 }
+
+// 埋め込み式を含む文字列
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${name}, you have ${count + 1} items"`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	expectedLiterals := []string{"Hello ", ", you have ", " items"}
+	if len(str.Literals) != len(expectedLiterals) {
+		t.Fatalf("wrong number of literals. want=%d, got=%d", len(expectedLiterals), len(str.Literals))
+	}
+	for i, lit := range expectedLiterals {
+		if str.Literals[i] != lit {
+			t.Errorf("literals[%d] wrong. want=%q, got=%q", i, lit, str.Literals[i])
+		}
+	}
+
+	if len(str.Exprs) != 2 {
+		t.Fatalf("wrong number of expressions. want=2, got=%d", len(str.Exprs))
+	}
+	testIdentifier(t, str.Exprs[0], "name")
+	testInfixExpression(t, str.Exprs[1], "count", "+", 1)
+
+	expectedString := `"Hello ${name}, you have ${(count + 1)} items"`
+	if str.String() != expectedString {
+		t.Errorf("str.String() wrong. want=%q, got=%q", expectedString, str.String())
+	}
+	if str.End().Offset != len(input) {
+		t.Errorf("str.End() wrong. want=%d, got=%d", len(input), str.End().Offset)
+	}
+
+	// 埋め込み式の先頭に入れ子の文字列を書ける
+	nested := []struct {
+		input    string
+		expected string
+	}{
+		{`"${"a${1}"}"`, `"${"a${1}"}"`},
+		{`"${ "${1}" }"`, `"${"${1}"}"`},
+		{`"x${"${"${y}"}"}z"`, `"x${"${"${y}"}"}z"`},
+	}
+	for _, tt := range nested {
+		p := New(lexer.New("", tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+// 埋め込み式の構文エラー
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a${}b"`, "1:5: empty expression in string interpolation"},
+		{`"a${x y}b"`, "1:7: expected } to close string interpolation, got INDENT"},
+		{`"a${x`, "1:6: expected } to close string interpolation, got EOF"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+// エラーメッセージの位置情報
+func TestParserErrorPosition(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x 5;", "test.aq:1:7: expected next token to be =, got INT instead"},
+		{"let x = 1;\nadd(1, 2;", "test.aq:2:9: expected next token to be ), got ; instead"},
+		{"\n  let = 5;", "test.aq:2:7: expected next token to be INDENT, got = instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("test.aq", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+// ノードの範囲
+func TestNodeSpan(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, 2)"
+
+	l := lexer.New("test.aq", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node          ast.Node
+		expectedPos   string
+		expectedEnd   string
+	}{
+		{program, "test.aq:1:1", "test.aq:4:10"},
+		{program.Statements[0], "test.aq:1:1", "test.aq:3:2"},
+		{program.Statements[0].(*ast.LetStatement).Value, "test.aq:1:11", "test.aq:3:2"},
+		{program.Statements[1], "test.aq:4:1", "test.aq:4:10"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedPos {
+			t.Errorf("test[%d] - pos wrong. expected=%q, got=%q", i, tt.expectedPos, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("test[%d] - end wrong. expected=%q, got=%q", i, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+// 配列リテラル
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+// 最後の要素の後のカンマ
+func TestTrailingComma(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1, 2]"},
+		{"add(\n1,\n2,\n)", "add(1, 2)"},
+		{"fn(x, y,) { x }", "fn(x, y) x"},
+		{"{\"a\": 1,}", "{a:1}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+// 添字式
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+// ハッシュリテラル
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+
+		if literal.String() != expected[i].key {
+			t.Errorf("key is not %q. got=%q", expected[i].key, literal.String())
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+// 空のハッシュリテラル
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+// 値が式のハッシュリテラル
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+}
+
+// while文
+func TestWhileStatement(t *testing.T) {
+	input := "while (x < 10) { x; break; continue; }"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[2] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+// for文
+func TestForStatement(t *testing.T) {
+	input := "for (x in [1, 2]) { x; };"
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Variable.Value != "x" {
+		t.Errorf("stmt.Variable.Value not %q. got=%q", "x", stmt.Variable.Value)
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if stmt.String() != "for (x in [1, 2]) x" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+// ループの外のbreak・continue
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"if (true) { continue; }", "1:13: continue outside loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+// import文とexport文
+func TestModuleStatements(t *testing.T) {
+	input := `import "lib/util.aq" as util;
+export let twice = fn(x) { util.double(x) };
+util.pi * 2;`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/util.aq" || imp.Name.Value != "util" {
+		t.Errorf("wrong import. got=%s", imp.String())
+	}
+
+	exp, ok := program.Statements[1].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ExportStatement. got=%T", program.Statements[1])
+	}
+	if !testLetStatement(t, exp.Statement, "twice") {
+		return
+	}
+	if fn, ok := exp.Statement.Value.(*ast.FunctionLiteral); !ok || fn.Name != "twice" {
+		t.Errorf("exported function is not named. got=%T", exp.Statement.Value)
+	}
+
+	expected := []string{
+		`import "lib/util.aq" as util;`,
+		`export let twice = fn(x) util.double(x);`,
+		`(util.pi * 2)`,
+	}
+	for i, stmt := range program.Statements {
+		got := stmt.String()
+		if i == 2 {
+			got = stmt.(*ast.ExpressionStatement).Expression.String()
+		}
+		if got != expected[i] {
+			t.Errorf("statement %d: expected=%q, got=%q", i, expected[i], got)
+		}
+	}
+}
+
+func TestModuleStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`import util`, "1:8: expected next token to be STRING, got INDENT instead"},
+		{`import "util.aq"`, "1:17: expected next token to be AS, got EOF instead"},
+		{`export fn() {}`, "1:8: expected next token to be LET, got FUNCTION instead"},
+		{`let f = fn() { import "a.aq" as a; }`, "1:16: import is only allowed at the top level"},
+		{`if (true) { export let x = 1; }`, "1:13: export is only allowed at the top level"},
+		{`util.1`, "1:6: expected next token to be INDENT, got INT instead"},
+		{`util.x = 1`, "1:1: cannot assign to util.x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+// 値を使うifの中のbreak・continue
+func TestLoopControlInValueIf(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string // 空ならエラーなし
+	}{
+		{"let i = 0; while (i < 3) { i += 1; let y = if (true) { break } else { 0 }; }", "1:56: break inside an if expression used as a value"},
+		{"for (i in [1, 2, 3]) { r = push(r, if (i == 2) { continue } else { i }) }", "1:50: continue inside an if expression used as a value"},
+		{"while (true) { 1 + if (true) { continue } else { 0 } }", "1:32: continue inside an if expression used as a value"},
+		{"while (true) { if (true) { break } else { 0 } + 1 }", "1:28: break inside an if expression used as a value"},
+		{"while (true) { let y = if (a) { if (b) { break } } else { 0 }; }", "1:42: break inside an if expression used as a value"},
+		// 文としてのifや、値を使うifの中のループ・関数の中なら使える
+		{"while (true) { if (a) { if (b) { continue } } else { break } }", ""},
+		{"while (true) { let y = if (a) { while (b) { break } 1 } else { 2 }; break; }", ""},
+		{"for (x in xs) { let f = if (a) { fn() { 1 } } else { fn() { 2 } }; continue; }", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if tt.expectedError == "" {
+			if len(errors) != 0 {
+				t.Errorf("%s: unexpected errors: %v", tt.input, errors)
+			}
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("%s: wrong errors. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+// 代入式
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x += 1 + 2", "(x += (1 + 2))"},
+		{"x = y = 3", "(x = (y = 3))"},
+		{"a[1] *= 2", "((a[1]) *= 2)"},
+		{"h[\"k\"] /= x - 1", "((h[k]) /= (x - 1))"},
+		{"x = fn(a) { a }", "(x = fn(a) a)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		if _, ok := stmt.Expression.(*ast.AssignExpression); !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+// 代入できない左辺
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 = 2", "1:1: cannot assign to 1"},
+		{"f() = 2", "1:1: cannot assign to f()"},
+		{"a + b += 1", "1:1: cannot assign to (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedError, errors[0])
+		}
+	}
+}
+
+// 浮動小数点数リテラル
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5e-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+// コメントを含むプログラムと字句解析のエラー
+func TestComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(x, y) {
+	x + y; /* sum */
+};
+add(1, /* two */ 2) // call`
+
+	l := lexer.New("", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let add = fn(x, y) (x + y);add(1, 2)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	l = lexer.New("", "let x = 1; /* open")
+	p = New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "1:12: unterminated block comment" {
+		t.Errorf("wrong errors. got=%v", errors)
+	}
+}
+
+// エラーの後は次の文から構文解析を続け、1つの誤りには1つのエラーだけを報告する
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"let x 5;\nlet y = 1;",
+			[]string{"1:7: expected next token to be =, got INT instead"},
+		},
+		{
+			"let a = (1 + ;\nlet b = add(1, 2;\nlet c = 3;",
+			[]string{
+				"1:14: expected an expression, got ;",
+				"2:17: expected next token to be ), got ; instead",
+			},
+		},
+		{
+			"let a = 1 +\nlet b = 2",
+			[]string{"2:1: expected an expression, got LET"},
+		},
+		{
+			"let f = fn(a) {\n  let b = a +;\n  b\n};\nlet g = );",
+			[]string{
+				"2:14: expected an expression, got ;",
+				"5:9: expected an expression, got )",
+			},
+		},
+		{
+			"if (x) { let = 1; y } else { z }; w)",
+			[]string{
+				"1:14: expected next token to be INDENT, got = instead",
+				"1:36: unexpected ) after statement",
+			},
+		},
+		{
+			"let a = [1, 2\nlet b = 1",
+			[]string{"2:1: expected next token to be ], got LET instead"},
+		},
+		{
+			"let x = 1 let y = 2\nx y",
+			[]string{
+				"1:11: unexpected LET after statement",
+				"2:3: unexpected INDENT after statement",
+			},
+		},
+		{
+			"let s = \"abc",
+			[]string{"1:9: unterminated string"},
+		},
+		{
+			"let s = /* abc",
+			[]string{"1:9: unterminated block comment"},
+		},
+		{
+			"let a = 1 & 2;",
+			[]string{"1:11: unexpected character \"&\""},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. want=%q, got=%q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("errors[%d] wrong for %q. want=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+// 構文エラーの範囲と予約語の書き間違いのヒント
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedPos  string
+		expectedEnd  string
+		expectedHint string
+	}{
+		{"lett x = 5;", "1:1", "1:5", "did you mean `let`?"},
+		{"retrun x;", "1:1", "1:7", "did you mean `return`?"},
+		{"retrun 5;", "1:1", "1:7", "did you mean `return`?"},
+		{"whlie (x) { x }", "1:1", "1:6", "did you mean `while`?"},
+		{"fro (i in xs) { i }", "1:1", "1:4", "did you mean `for`?"},
+		{"let x = 1 y = 2", "1:11", "1:12", "use ; to separate statements on the same line"},
+		{"let x = foo;)", "1:13", "1:14", ""},
+		{"1 = 2", "1:1", "1:2", ""},
+		{"it x", "1:4", "1:5", "use ; to separate statements on the same line"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New("", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("expected 1 diagnostic for %q. got=%q", tt.input, p.Errors())
+			continue
+		}
+		d := diagnostics[0]
+		if d.Severity != diag.Error {
+			t.Errorf("wrong severity for %q. got=%s", tt.input, d.Severity)
+		}
+		if d.Pos.String() != tt.expectedPos || d.End.String() != tt.expectedEnd {
+			t.Errorf("wrong span for %q. want=%s-%s, got=%s-%s", tt.input, tt.expectedPos, tt.expectedEnd, d.Pos, d.End)
+		}
+		if d.Hint != tt.expectedHint {
+			t.Errorf("wrong hint for %q. want=%q, got=%q", tt.input, tt.expectedHint, d.Hint)
+		}
+	}
+}
+
+func TestSuggestKeyword(t *testing.T) {
+	tests := []struct {
+		ident    string
+		expected string
+	}{
+		{"lett", "let"},
+		{"retrun", "return"},
+		{"whlie", "while"},
+		{"contineu", "continue"},
+		{"brek", "break"},
+		{"ture", "true"},
+		{"it", ""},
+		{"fun", ""},
+		{"value", ""},
+	}
+
+	for _, tt := range tests {
+		if got := suggestKeyword(tt.ident); got != tt.expected {
+			t.Errorf("suggestKeyword(%q) wrong. want=%q, got=%q", tt.ident, tt.expected, got)
+		}
+	}
+}
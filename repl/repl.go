@@ -4,46 +4,86 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/compiler"
+	"github.com/takeru-a/golang_interpreterlang/diag"
 	"github.com/takeru-a/golang_interpreterlang/evaluator"
 	"github.com/takeru-a/golang_interpreterlang/lexer"
 	"github.com/takeru-a/golang_interpreterlang/object"
 	"github.com/takeru-a/golang_interpreterlang/parser"
+	"github.com/takeru-a/golang_interpreterlang/vm"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// 実行エンジン
+const (
+	EngineEval = "eval" // 構文木をたどって評価する
+	EngineVM   = "vm"   // バイトコードにコンパイルして仮想機械で実行する
+)
+
+// hostは組み込み関数が使う外の世界 (出力先が無ければoutputもoutに書く)
+func Start(in io.Reader, out io.Writer, engine string, host *object.Host) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	if host.Stdout == nil {
+		host.Stdout = out
+	}
 
-	fmt.Println(AQUAMARINE)
+	var run func(program *ast.Program) object.Object
+	if engine == EngineVM {
+		run = newVMRunner(out, host)
+	} else {
+		env := object.NewEnvironment()
+		env.SetHost(host)
+		run = func(program *ast.Program) object.Object {
+			return evaluator.Eval(program, env)
+		}
+	}
+
+	fmt.Fprint(out, AQUAMARINE)
 
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan() 
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New("<stdin>", line)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, p.Diagnostics())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		evaluated := runSafely(out, run, program)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, errObj.Traceback())
+			io.WriteString(out, "\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
 	}
 }
 
+// 実行中にGoのpanicが起きても、その行だけをエラーにしてREPLを続ける
+func runSafely(out io.Writer, run func(program *ast.Program) object.Object, program *ast.Program) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(out, "internal error: %v\n", r)
+			result = nil
+		}
+	}()
+
+	return run(program)
+}
+
 const AQUAMARINE = `
   ___                                                _
  / _ \                                              (_)
@@ -56,10 +96,54 @@ const AQUAMARINE = `
 
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+// 入力行をまたいで定数・グローバル変数を引き継ぐ仮想機械での実行
+func newVMRunner(out io.Writer, host *object.Host) func(program *ast.Program) object.Object {
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return func(program *ast.Program) object.Object {
+		comp := compiler.NewWithState(symbolTable, constants)
+		err := comp.Compile(program)
+		if err != nil {
+			fmt.Fprintf(out, "Compilation failed:\n\t%s\n", err)
+			return nil
+		}
+
+		code := comp.Bytecode()
+		constants = code.Constants
+
+		machine := vm.NewWithGlobalsState(code, globals)
+		machine.SetHost(host)
+		err = machine.Run()
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Executing bytecode failed:\n\t%s\n", err)
+			return nil
+		}
+
+		// let文などで終わる行は、evaluatorと同じく値を表示しない
+		n := len(program.Statements)
+		if n == 0 {
+			return nil
+		}
+		if _, ok := program.Statements[n-1].(*ast.ExpressionStatement); !ok {
+			return nil
+		}
+
+		return machine.LastPoppedStackElem()
+	}
+}
+
+func printParserErrors(out io.Writer, diagnostics []diag.Diagnostic) {
 	io.WriteString(out, "Error in Aquamarine script")
 	io.WriteString(out, " syntax errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		io.WriteString(out, "\t"+strings.ReplaceAll(d.Format(), "\n", "\n\t")+"\n")
 	}
 }
@@ -0,0 +1,62 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+// 1行で起きたpanicはその行のエラーになる
+func TestRunSafely(t *testing.T) {
+	var out bytes.Buffer
+	run := func(program *ast.Program) object.Object {
+		panic("boom")
+	}
+
+	result := runSafely(&out, run, &ast.Program{})
+	if result != nil {
+		t.Errorf("result is not nil. got=%+v", result)
+	}
+	if out.String() != "internal error: boom\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+// エラーの後も続けて入力を評価する
+func TestStartContinuesAfterError(t *testing.T) {
+	for _, engine := range []string{EngineEval, EngineVM} {
+		var out bytes.Buffer
+		in := strings.NewReader("let f = fn(x) { x };\nf()\nlet g = fn() { 1 / 0 };\ng()\nf(2)\n")
+
+		Start(in, &out, engine, &object.Host{Grants: object.CapAll})
+
+		expected := "ERROR: <stdin>:1:1: wrong number of arguments to f: want=1, got=0\n" +
+			"ERROR: <stdin>:1:16: division by zero\n" +
+			"    at g (<stdin>:1:1)\n" +
+			"2\n"
+		output := strings.ReplaceAll(out.String(), PROMPT, "")
+		if !strings.HasSuffix(output, expected) {
+			t.Errorf("%s: wrong output. got=%q", engine, out.String())
+		}
+	}
+}
+
+// outputはREPLの出力先に書き、許可していない権限はエラーになる
+func TestStartHost(t *testing.T) {
+	for _, engine := range []string{EngineEval, EngineVM} {
+		var out bytes.Buffer
+		in := strings.NewReader("output(\"hello\")\nnow()\n")
+
+		Start(in, &out, engine, &object.Host{Grants: object.CapIO})
+
+		expected := "hello\n\n" +
+			"ERROR: <stdin>:1:1: permission denied: now requires the time capability\n"
+		output := strings.ReplaceAll(out.String(), PROMPT, "")
+		if !strings.HasSuffix(output, expected) {
+			t.Errorf("%s: wrong output. got=%q", engine, out.String())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/takeru-a/golang_interpreterlang/ast"
+	"github.com/takeru-a/golang_interpreterlang/compiler"
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+	"github.com/takeru-a/golang_interpreterlang/repl"
+	"github.com/takeru-a/golang_interpreterlang/vm"
+)
+
+// 終了コード
+const (
+	exitOK    = 0
+	exitError = 1 // 構文エラー・実行時エラー
+	exitUsage = 2 // 引数・ファイルの誤り
+)
+
+// スクリプトファイルを実行する ("-" は標準入力)
+func runFile(path string, args []string, engine string, host *object.Host) int {
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(os.Stdin)
+		path = "<stdin>"
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	// 実行するファイル自身をimportしたときも循環として扱う
+	if abs, err := filepath.Abs(path); err == nil && path != "<stdin>" {
+		host.Modules.Begin(abs, path)
+	}
+
+	_, code := execute(path, string(src), args, engine, host)
+	return code
+}
+
+// -eで渡された式を実行し、結果をoutputと同じ出力先に表示する
+func runExpr(src string, args []string, engine string, host *object.Host) int {
+	result, code := execute("-e", src, args, engine, host)
+	if code == exitOK && result != nil && result != evaluator.NULL && result != evaluator.NULLSTRING {
+		fmt.Fprintln(host.Output(), result.Inspect())
+	}
+	return code
+}
+
+// 構文解析して、hostの権限で評価する
+func execute(name, src string, args []string, engine string, host *object.Host) (object.Object, int) {
+	l := lexer.New(name, src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		for _, d := range p.Diagnostics() {
+			fmt.Fprintln(os.Stderr, d.Format())
+		}
+		return nil, exitError
+	}
+
+	host.Args = args
+
+	var result object.Object
+	if engine == repl.EngineVM {
+		var err error
+		result, err = runVM(program, host)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, exitError
+		}
+	} else {
+		env := object.NewEnvironment()
+		env.SetHost(host)
+		result = evaluator.Eval(program, env)
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, errObj.Traceback())
+		return result, exitError
+	}
+
+	return result, exitOK
+}
+
+// バイトコードにコンパイルして仮想機械で実行する
+// 実行時エラーは*object.Errorの結果として返す
+func runVM(program *ast.Program, host *object.Host) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetHost(host)
+	err := machine.Run()
+	if errObj, ok := err.(*object.Error); ok {
+		return errObj, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// let文やループで終わるときは、evaluatorと同じく値が無い
+	// (最後に取り出したスタックの値は前の文のもの)
+	n := len(program.Statements)
+	if n == 0 {
+		return nil, nil
+	}
+	if _, ok := program.Statements[n-1].(*ast.ExpressionStatement); !ok {
+		return nil, nil
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
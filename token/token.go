@@ -1,11 +1,52 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 // トークンの構造体
 type Token struct {
 	Type    TokenType   // トークンの種類
 	Literal string      // 文字
+	Pos     Position    // 開始位置
+	End     Position    // 終了位置 (トークン直後の位置)
+	Leading []Comment   // トークンの前にあるコメント (整形ツールなどで使う)
+}
+
+// コメント
+type Comment struct {
+	Text string   // "//"や"/*"を含むコメントの文字列
+	Pos  Position // 開始位置
+	End  Position // 終了位置 (コメント直後の位置)
+}
+
+// ソース上の位置
+type Position struct {
+	Filename string // ファイル名 (無い場合は空)
+	Offset   int    // バイトオフセット (0始まり)
+	Line     int    // 行番号 (1始まり)
+	Column   int    // 列番号 (1始まり)
+}
+
+// 位置情報が設定されているか
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// file:line:col 形式の文字列表現
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 // 予約語
@@ -17,6 +58,24 @@ var keyword = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+}
+
+// 予約語の一覧 (アルファベット順)
+func Keywords() []string {
+	keywords := make([]string, 0, len(keyword))
+	for k := range keyword {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+	return keywords
 }
 
 // 予約語判定
@@ -35,6 +94,7 @@ const (
 
 	INDENT = "INDENT" // 識別子 (add, x, yなど　変数、定数、関数の名前)
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 
 	// 演算子
 	ASSIGN   = "="
@@ -43,18 +103,35 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
+
+	// 複合代入
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
 
 	LT        = "<"
 	GT        = ">"
+	LT_EQ     = "<="
+	GT_EQ     = ">="
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -63,7 +140,22 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
 
 	// 文字列
 	STRING = "STRING"
+
+	// 埋め込み式を含む文字列 "a${x}b${y}c" は
+	// TEMPLATE_PART("a") x TEMPLATE_MID("b") y TEMPLATE_END("c") に分ける
+	// (埋め込み式の後から続く部分は、入れ子の文字列の始まりと区別できるようTEMPLATE_MIDにする)
+	TEMPLATE_PART = "TEMPLATE_PART"
+	TEMPLATE_MID  = "TEMPLATE_MID"
+	TEMPLATE_END  = "TEMPLATE_END"
 )
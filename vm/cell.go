@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+const CELL_OBJ = "CELL"
+
+// クロージャに取り込まれた変数
+// 局所変数のスロットとクロージャが同じセルを指すことで書き換えを共有する
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return CELL_OBJ }
+func (c *cell) Inspect() string         { return "cell" }
+
+// セルなら中身を、そうでなければそのまま返す
+func deref(obj object.Object) object.Object {
+	if c, ok := obj.(*cell); ok {
+		return c.value
+	}
+	return obj
+}
+
+// スロットの値をセルにして返す (既にセルならそのセルを返す)
+func boxSlot(slot *object.Object) *cell {
+	if c, ok := (*slot).(*cell); ok {
+		return c
+	}
+	c := &cell{value: *slot}
+	*slot = c
+	return c
+}
+
+// スロットに代入する (セルならセルの中身を書き換える)
+func storeSlot(slot *object.Object, val object.Object) {
+	if c, ok := (*slot).(*cell); ok {
+		c.value = val
+		return
+	}
+	*slot = val
+}
@@ -0,0 +1,27 @@
+package vm
+
+import (
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// for文で使う反復子
+// 要素はループの開始時に取り出しておく
+type iterator struct {
+	elements []object.Object
+	index    int
+}
+
+func (it *iterator) Type() object.ObjectType { return ITERATOR_OBJ }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// 次の要素を返す。要素が無ければfalse
+func (it *iterator) next() (object.Object, bool) {
+	if it.index >= len(it.elements) {
+		return nil, false
+	}
+	el := it.elements[it.index]
+	it.index++
+	return el, true
+}
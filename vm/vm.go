@@ -0,0 +1,598 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/takeru-a/golang_interpreterlang/code"
+	"github.com/takeru-a/golang_interpreterlang/compiler"
+	"github.com/takeru-a/golang_interpreterlang/evaluator"
+	"github.com/takeru-a/golang_interpreterlang/object"
+)
+
+const StackSize = 2048 // スタックの最初の大きさ (足りなくなれば広げる)
+const GlobalsSize = 65536
+
+// 呼び出しの深さはevaluatorと同じだけ許す (一番外側のフレームの分を足す)
+const MaxFrames = evaluator.DefaultMaxDepth + 1
+
+// フレームの最初の数 (足りなくなればMaxFramesまで広げる)
+const initialFrames = 64
+
+// 演算の結果などはevaluatorと同じオブジェクトを使う
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// スタック型の仮想機械
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // 次に積む位置 (スタックの先頭はstack[sp-1])
+
+	globals     []object.Object
+	globalNames []string
+
+	builtins []*object.Builtin
+	host     *object.Host // 組み込み関数が使う外の世界 (nilなら制限しない)
+
+	frames      []*Frame
+	framesIndex int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, initialFrames)
+	frames[0] = mainFrame
+
+	builtins := []*object.Builtin{}
+	for _, name := range evaluator.BuiltinNames() {
+		builtin, _ := evaluator.LookupBuiltin(name)
+		builtins = append(builtins, builtin)
+	}
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.Globals,
+
+		builtins: builtins,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// REPLのようにグローバル変数を引き継いで実行する
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// 組み込み関数が使うホストを設定する
+func (vm *VM) SetHost(h *object.Host) {
+	vm.host = h
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+// スタックにn個分の場所があるようにする
+func (vm *VM) growStack(n int) {
+	if n <= len(vm.stack) {
+		return
+	}
+	size := 2 * len(vm.stack)
+	for size < n {
+		size *= 2
+	}
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// 最後に取り除かれた値 (プログラムの結果)
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// 実行する
+// 実行時エラーは位置情報付きの*object.Errorとして返す
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpBang:
+			err = vm.push(evaluator.EvalPrefix("!", vm.pop()))
+
+		case code.OpMinus:
+			err = vm.push(evaluator.EvalPrefix("-", vm.pop()))
+
+		case code.OpTrue:
+			err = vm.push(True)
+
+		case code.OpFalse:
+			err = vm.push(False)
+
+		case code.OpNull:
+			err = vm.push(Null)
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			val := vm.globals[globalIndex]
+			if val == nil {
+				err = &object.Error{Message: "identifier not found: " + vm.globalName(int(globalIndex))}
+				break
+			}
+			err = vm.push(val)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			storeSlot(&vm.stack[frame.basePointer+int(localIndex)], vm.pop())
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			err = vm.push(deref(vm.stack[frame.basePointer+int(localIndex)]))
+
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			err = vm.push(boxSlot(&vm.stack[frame.basePointer+int(localIndex)]))
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.builtins[builtinIndex])
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err = vm.push(deref(currentClosure.Free[freeIndex]))
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			storeSlot(&currentClosure.Free[freeIndex], vm.pop())
+
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			err = vm.push(boxSlot(&currentClosure.Free[freeIndex]))
+
+		case code.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().cl)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(array)
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err = vm.push(str)
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				break
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.push(evaluator.EvalIndex(left, index))
+
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.push(evaluator.SetIndex(left, index, val))
+
+		case code.OpUpdateIndex:
+			op := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			current := evaluator.EvalIndex(left, index)
+			if errObj, ok := current.(*object.Error); ok {
+				err = errObj
+				break
+			}
+			val = evaluator.EvalInfix(binaryOperators[op], current, val)
+			if errObj, ok := val.(*object.Error); ok {
+				err = errObj
+				break
+			}
+			err = vm.push(evaluator.SetIndex(left, index, val))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// トップレベルのreturnはプログラムを終了する
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err = vm.push(Null)
+
+		case code.OpIter:
+			elements, errObj := evaluator.IterableElements(vm.pop())
+			if errObj != nil {
+				err = errObj
+				break
+			}
+			err = vm.push(&iterator{elements: elements})
+
+		case code.OpIterNext:
+			afterLoop := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			it := vm.pop().(*iterator)
+			el, ok := it.next()
+			if !ok {
+				vm.currentFrame().ip = afterLoop - 1
+				break
+			}
+			err = vm.push(el)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			err = fmt.Errorf("unhandled opcode %v", def)
+		}
+
+		if err != nil {
+			return vm.attachPosition(err, ip)
+		}
+	}
+
+	return nil
+}
+
+// 実行時エラーに、エラーを起こした命令のソース上の位置を付ける
+func (vm *VM) attachPosition(err error, ip int) error {
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		return err
+	}
+	if !errObj.Pos.IsValid() {
+		errObj.Pos = vm.currentFrame().cl.Fn.Positions[ip]
+	}
+
+	// 呼び出し履歴 (呼び出し元のipはOpCallのオペランドを指している)
+	if len(errObj.Stack) == 0 {
+		for i := vm.framesIndex - 1; i > 0; i-- {
+			caller := vm.frames[i-1]
+			errObj.Stack = append(errObj.Stack, object.StackFrame{
+				Function: vm.frames[i].cl.Fn.DisplayName(),
+				Pos:      caller.cl.Fn.Positions[caller.ip-1],
+			})
+		}
+	}
+
+	return errObj
+}
+
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global#%d", index)
+}
+
+func (vm *VM) push(o object.Object) error {
+	// 演算の結果がエラーなら実行を止める
+	if errObj, ok := o.(*object.Error); ok {
+		return errObj
+	}
+
+	vm.growStack(vm.sp + 1)
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// 中置演算子
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpMod:         "%",
+	code.OpPow:         "**",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	// 整数同士のよく使う演算は直接計算する
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				return vm.push(&object.Integer{Value: l.Value + r.Value})
+			case code.OpSub:
+				return vm.push(&object.Integer{Value: l.Value - r.Value})
+			case code.OpMul:
+				return vm.push(&object.Integer{Value: l.Value * r.Value})
+			case code.OpLessThan:
+				return vm.push(nativeBoolToBooleanObject(l.Value < r.Value))
+			case code.OpGreaterThan:
+				return vm.push(nativeBoolToBooleanObject(l.Value > r.Value))
+			case code.OpLessEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value <= r.Value))
+			case code.OpGreaterEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value >= r.Value))
+			case code.OpEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value == r.Value))
+			case code.OpNotEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value != r.Value))
+			}
+		}
+	}
+
+	return vm.push(evaluator.EvalInfix(binaryOperators[op], left, right))
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// 埋め込み式を含む文字列を組み立てる
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(object.Display(vm.stack[i]))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, &object.Error{Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return &object.Error{Message: fmt.Sprintf("not a function: %s", callee.Type())}
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments to %s: want=%d, got=%d",
+			cl.Fn.DisplayName(), cl.Fn.NumParameters, numArgs)}
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return &object.Error{Message: "stack overflow"}
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	vm.growStack(frame.basePointer + cl.Fn.NumLocals)
+
+	// 前の呼び出しで残ったセルを書き換えないよう局所変数を空にする
+	for i := frame.basePointer + numArgs; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.pushFrame(frame)
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Call(vm.host, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = Null
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
@@ -0,0 +1,652 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/takeru-a/golang_interpreterlang/compiler"
+	"github.com/takeru-a/golang_interpreterlang/lexer"
+	"github.com/takeru-a/golang_interpreterlang/object"
+	"github.com/takeru-a/golang_interpreterlang/parser"
+)
+
+// evaluator_test.goと同じ入力で同じ結果になることを確認する
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+// 期待するエラーメッセージ
+type errorMessage string
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		result := testRun(t, tt.input)
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+// コンパイルして実行し、結果 (実行時エラーも含む) を返す
+func testRun(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	l := lexer.New("", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if errObj, ok := err.(*object.Error); ok {
+		return errObj
+	}
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	return vm.LastPoppedStackElem()
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != int64(expected) {
+			t.Errorf("%s: wrong integer. want=%d, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case float64:
+		result, ok := actual.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: wrong float. want=%g, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: wrong boolean. want=%t, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: wrong string. want=%q, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: wrong array. want=%v, got=%T (%+v)", input, expected, actual, actual)
+			return
+		}
+		for i, expectedElem := range expected {
+			testExpectedObject(t, input, expectedElem, array.Elements[i])
+		}
+
+	case errorMessage:
+		errObj, ok := actual.(*object.Error)
+		if !ok || errObj.Message != string(expected) {
+			t.Errorf("%s: wrong error. want=%q, got=%T (%+v)", input, expected, actual, actual)
+		}
+
+	case nil:
+		if actual != Null {
+			t.Errorf("%s: object is not Null. got=%T (%+v)", input, actual, actual)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{`"a" < "b"`, true},
+		{`"ab" >= "ab"`, true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"1 < 2 || 1 / 0", true},
+		{"1 > 2 && 1 / 0", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 10;", 10},
+		{"return 10; 9", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{
+			`
+		if (10 > 1) {
+			if (10 > 1) {
+				return 10;
+			}
+
+		return 1;
+		}
+		`,
+			10,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []vmTestCase{
+		{"5 + true;", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"5 + true; 5;", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"-true", errorMessage("unknown operator: -BOOLEAN")},
+		{"true + false;", errorMessage("unknown operator: BOOLEAN + BOOLEAN")},
+		{"5; true + false; 5", errorMessage("unknown operator: BOOLEAN + BOOLEAN")},
+		{"if (10 > 1) { true + false; }", errorMessage("unknown operator: BOOLEAN + BOOLEAN")},
+		{
+			`
+			if (10 > 1) {
+				if (10 > 1) {
+					return true + false;
+				}
+
+			return 1;
+			}
+			`,
+			errorMessage("unknown operator: BOOLEAN + BOOLEAN"),
+		},
+		{"foobar", errorMessage("identifier not found: foobar")},
+		{`"Hello" - "World"`, errorMessage("unknown operator: STRING - STRING")},
+		{"if (false) { foobar }; 1", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+// エラーの位置情報
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:1"},
+		{"let a = 1;\nlet b = -true;", "2:9"},
+		{"let f = fn(x) {\n  x + foo;\n};\nf(1);", "2:7"},
+		{`len(1)`, "1:1"},
+	}
+
+	for _, tt := range tests {
+		result := testRun(t, tt.input)
+
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", result, result)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%q, got=%q", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 1; let a = a + 1; a", 2},
+		{"let f = fn() { g }; let g = 3; f()", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionObject(t *testing.T) {
+	result := testRun(t, "fn(x) { x + 2; };")
+
+	closure, ok := result.(*object.Closure)
+	if !ok {
+		t.Fatalf("object is not Closure. got=%T (%+v)", result, result)
+	}
+
+	if closure.Fn.NumParameters != 1 {
+		t.Fatalf("function has wrong parameters. got=%d", closure.Fn.NumParameters)
+	}
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { }; f()", nil},
+		{"let f = fn() { return 1; }; f() + 1", 2},
+		{"let f = fn(x) { let y = x * 2; y + 1 }; f(3)", 7},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+	let newAdder = fn(x) {
+		fn(y) { x + y };
+	};
+
+	let addTwo = newAdder(2);
+	addTwo(2);
+	`,
+			4,
+		},
+		{
+			`
+	let newAdderOuter = fn(a, b) {
+		let c = a + b;
+		fn(d) {
+			let e = d + c;
+			fn(f) { e + f; };
+		};
+	};
+	let newAdderInner = newAdderOuter(1, 2)
+	let adder = newAdderInner(3);
+	adder(8);
+	`,
+			14,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+	let fibonacci = fn(x) {
+		if (x == 0) { return 0; }
+		if (x == 1) { return 1; }
+		fibonacci(x - 1) + fibonacci(x - 2);
+	};
+	fibonacci(15);
+	`,
+			610,
+		},
+		{
+			`
+	let wrapper = fn() {
+		let countDown = fn(x) {
+			if (x == 0) { return 0; }
+			countDown(x - 1);
+		};
+		countDown(1);
+	};
+	wrapper();
+	`,
+			0,
+		},
+		{"let f = fn() { f() }; f()", errorMessage("stack overflow")},
+		// evaluatorと同じ深さまで呼び出せる
+		{"let s = fn(n) { if (n == 0) { 0 } else { n + s(n - 1) } }; s(2000)", 2001000},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(9999)", 0},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10000)", errorMessage("stack overflow")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"hello world!"`, "hello world!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"Hello" == "Hello"`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, errorMessage("argument to `len` not supported, got INTEGER")},
+		{`len("one", "two")`, errorMessage("wrong number of arguments. got=2, want=1")},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int{2, 3}},
+		{`concat([1], [2, 3])`, []int{1, 2, 3}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArraysAndHashes(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2 * 2, 3 + 3]", []int{1, 4, 6}},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][3]", errorMessage("index out of range: 3 (len=3)")},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`{1: 1, 2: 2}[2]`, 2},
+		{`{fn(x) { x }: 1}`, errorMessage("unusable as hash key: CLOSURE")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; }; let s = s + x; }; s", 8},
+		{`let s = ""; for (k in {"a": 1, "b": 2}) { let s = s + k; }; s`, "ab"},
+		{`let s = ""; for (c in "abc") { let s = c + s; }; s`, "cba"},
+		{"let s = 0; for (x in []) { let s = 1; }; s", 0},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } }; 0 }; f()", 20},
+		{"let f = fn(n) { let i = 0; while (i < n) { let i = i + 1; }; i }; f(4)", 4},
+		{
+			`let n = 0;
+			for (x in [1, 2, 3]) {
+				for (y in [1, 2, 3]) {
+					if (y > x) { break; }
+					let n = n + 1;
+				}
+			};
+			n`,
+			6,
+		},
+		{"for (x in 5) { x }", errorMessage("not iterable: INTEGER")},
+		{"while (true) { 1 + true; }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoopControlInValueIf(t *testing.T) {
+	for _, input := range []string{
+		"let i = 0; while (i < 5) { i += 1; let y = if (true) { break } else { 0 }; }; i",
+		"let r = []; for (i in [1, 2, 3]) { r = push(r, if (i == 2) { continue } else { i }) }; r",
+		"let i = 0; while (i < 5) { i += 1; 1 + if (true) { continue } else { 0 } }; i",
+	} {
+		p := parser.New(lexer.New("", input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { i += 1; if (true) { break } else { 0 }; }; i", 1},
+		{"let r = []; for (i in [1, 2, 3]) { if (i == 2) { continue } else { r = push(r, i) } }; r[0] * 10 + r[1]", 13},
+		{"let i = 0; while (i < 5) { i += 1; if (true) { continue } else { 0 }; i += 10 }; i", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let i = 0; while (i < 5) { i += 1; }; i", 5},
+		{"let x = 1; let f = fn() { x = 2; }; f(); x", 2},
+		{"let x = 1; let f = fn() { let x = 5; x = 2; }; f(); x", 1},
+		{"let f = fn(n) { let i = 0; while (i < n) { i += 1; }; i }; f(4)", 4},
+		{
+			`let counter = fn() {
+				let n = 0;
+				fn() { n += 1; n }
+			};
+			let c = counter();
+			c(); c();
+			let d = counter();
+			d();
+			c()`,
+			3,
+		},
+		{
+			// 入れ子のクロージャも同じ変数を共有する
+			`let f = fn() {
+				let k = 1;
+				let double = fn() { k *= 2 };
+				let makeInc = fn() { fn() { k += 1 } };
+				double(); makeInc()(); double();
+				k
+			};
+			f() + f()`,
+			12,
+		},
+		{
+			// 前の呼び出しのセルが次の呼び出しに残らない
+			`let f = fn(x) { let y = x; let g = fn() { y }; g };
+			let a = f(1);
+			let b = f(2);
+			a() + b()`,
+			3,
+		},
+		{"let a = [1, 2, 3]; a[0] = 10; a[-1] *= 5; a", []int{10, 2, 15}},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{`let h = {"x": 1}; h["y"] = 2; h["x"] -= 3; h["x"] + h["y"]`, 0},
+		{"let a = [[1], [2]]; a[1][0] += 40; a[1]", []int{42}},
+		{"let x = 1; x += true", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{"let a = [1]; a[1] = 2", errorMessage("index out of range: 1 (len=1)")},
+		{"let a = [1]; a[1] += 2", errorMessage("index out of range: 1 (len=1)")},
+		{"let h = {}; h[[1]] = 2", errorMessage("unusable as hash key: ARRAY")},
+		{`let s = "ab"; s[0] = "c"`, errorMessage("index assignment not supported: STRING")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"7 / 2", 3},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"1 + 0.5", 1.5},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"1 == 1.0", true},
+		{"2 > 1.5", true},
+		{"round(3.14159, 2)", 3.14},
+		{"floor(-1.5)", -2},
+		{"1.5 + true", errorMessage("type mismatch: FLOAT + BOOLEAN")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 / 0", errorMessage("division by zero")},
+		{"let x = 0; 10 / x", errorMessage("division by zero")},
+		{"let x = 5; x /= 0", errorMessage("division by zero")},
+		{"5 % 0", errorMessage("modulo by zero")},
+		{`"a" < 1`, errorMessage("type mismatch: STRING < INTEGER")},
+		{"let x = 0; false || x / 0", errorMessage("division by zero")},
+		{"let add = fn(a, b) { a + b }; add(1)", errorMessage("wrong number of arguments to add: want=2, got=1")},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", errorMessage("wrong number of arguments to add: want=2, got=3")},
+		{"fn(x) { x }()", errorMessage("wrong number of arguments to <anonymous>: want=1, got=0")},
+		{"let f = fn() { let x = 1; }; f()", nil},
+		{"let x = if (true) { let y = 1; }; x", nil},
+	}
+
+	runVmTests(t, tests)
+}
+
+// エラーの呼び出し履歴 (evaluatorと同じになる)
+func TestErrorStack(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStack []string
+	}{
+		{"1 + true", []string{}},
+		{"let f = fn() { 1 + true }; f()", []string{"f 1:28"}},
+		{
+			"let inner = fn(x) {\n  x + foo\n};\nlet apply = fn(f, v) { f(v) };\nlet outer = fn() {\n  apply(fn(y) { inner(y) }, 1)\n};\nouter();",
+			[]string{"inner 6:17", "<anonymous> 4:24", "apply 6:3", "outer 8:1"},
+		},
+		{"let f = fn(x) { x }; let g = fn() { f() }; g()", []string{"g 1:44"}},
+	}
+
+	for _, tt := range tests {
+		result := testRun(t, tt.input)
+
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", result, result)
+			continue
+		}
+
+		stack := []string{}
+		for _, frame := range errObj.Stack {
+			stack = append(stack, frame.Function+" "+frame.Pos.String())
+		}
+		if strings.Join(stack, ", ") != strings.Join(tt.expectedStack, ", ") {
+			t.Errorf("wrong stack for %q. expected=%v, got=%v", tt.input, tt.expectedStack, stack)
+		}
+	}
+}
+
+func TestStringUnicode(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("アクア")`, 3},
+		{`byteLen("アクア")`, 9},
+		{`let 名前 = "アクアマリン"; 名前[-1]`, "ン"},
+		{`slice("アクアマリン", 1, -2)`, "クアマ"},
+		{`let s = ""; for (c in "日本語") { s = c + s; }; s`, "語本日"},
+		{`"アクア"[3]`, errorMessage("index out of range: 3 (len=3)")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []vmTestCase{
+		{`let name = "Aqua"; let count = 2; "Hello ${name}, you have ${count + 1} items"`, "Hello Aqua, you have 3 items"},
+		{`"${1.5} ${true} ${if (false) { 1 }}"`, "1.5 true null"},
+		{`"${[1, "a"]} ${{"k": "v"}}"`, `[1, "a"] {"k": "v"}`},
+		{`"${"a" + "${1 + 1}"}!"`, "a2!"},
+		{`"${"a${1}"}"`, "a1"},
+		{`"${ "${1}" }!"`, "1!"},
+		{`let f = fn(n) { "n=${n}" }; f(4)`, "n=4"},
+		{`"${len(1)}"`, errorMessage("argument to `len` not supported, got INTEGER")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"1" == 1`, false},
+		{"1 == 1.0", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"len == len", true},
+		{`{1: "x"}[1.0]`, "x"},
+	}
+
+	runVmTests(t, tests)
+}